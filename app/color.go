@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+)
+
+// palette holds the SGR parameters used to colour each part of the output, named after
+// their GREP_COLORS capability. An empty entry prints that part without colour, so the
+// zero palette prints plain text.
+type palette struct {
+	selectedMatch string // ms: matched text in a selected line
+	contextMatch  string // mc: matched text in a context line
+	fileName      string // fn
+	lineNumber    string // ln, also used for --column
+	byteOffset    string // bn
+	separator     string // se: the separators after prefixes and between context groups
+}
+
+// defaultPalette is GNU grep's default colouring.
+var defaultPalette = palette{
+	selectedMatch: "01;31",
+	contextMatch:  "01;31",
+	fileName:      "35",
+	lineNumber:    "32",
+	byteOffset:    "32",
+	separator:     "36",
+}
+
+// outputPalette returns the colours for --color=when, where terminal tells whether
+// output goes to a terminal and getenv looks up the environment. With auto, a set
+// NO_COLOR or a dumb terminal turns colours off.
+func outputPalette(when string, terminal bool, getenv func(string) string) palette {
+	switch when {
+	case "always":
+	case "auto":
+		if !terminal || getenv("NO_COLOR") != "" || getenv("TERM") == "dumb" {
+			return palette{}
+		}
+	default:
+		return palette{}
+	}
+	return parseGrepColors(defaultPalette, getenv("GREP_COLORS"))
+}
+
+// parseGrepColors applies a GREP_COLORS value such as "ms=01;32:fn=34" to p. Like GNU
+// grep, it ignores capabilities it does not know.
+func parseGrepColors(p palette, spec string) palette {
+	for _, field := range strings.Split(spec, ":") {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "mt":
+			p.selectedMatch, p.contextMatch = value, value
+		case "ms":
+			p.selectedMatch = value
+		case "mc":
+			p.contextMatch = value
+		case "fn":
+			p.fileName = value
+		case "ln":
+			p.lineNumber = value
+		case "bn":
+			p.byteOffset = value
+		case "se":
+			p.separator = value
+		}
+	}
+	return p
+}
+
+// appendColored appends text to b, wrapped in the escape sequences for sgr unless it is
+// empty.
+func appendColored[T string | []byte](b []byte, sgr string, text T) []byte {
+	if sgr == "" {
+		return append(b, text...)
+	}
+	b = append(b, "\x1b["...)
+	b = append(b, sgr...)
+	b = append(b, "m\x1b[K"...)
+	b = append(b, text...)
+	return append(b, "\x1b[m\x1b[K"...)
+}
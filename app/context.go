@@ -0,0 +1,45 @@
+package main
+
+// contextLine is a line held back in case it turns out to be before-context.
+type contextLine struct {
+	lineNo int
+	offset int
+	text   []byte // With its line terminator
+}
+
+// contextRing keeps the last lines that were not printed, up to the -B count, reusing
+// their buffers as it wraps around.
+type contextRing struct {
+	lines []contextLine
+	start int // Index of the oldest line
+	n     int // Number of lines held
+}
+
+func newContextRing(size int) *contextRing {
+	return &contextRing{lines: make([]contextLine, size)}
+}
+
+// push adds a copy of text, dropping the oldest line if the ring is full.
+func (r *contextRing) push(lineNo, offset int, text []byte) {
+	if len(r.lines) == 0 {
+		return
+	}
+
+	i := (r.start + r.n) % len(r.lines)
+	if r.n == len(r.lines) {
+		r.start = (r.start + 1) % len(r.lines)
+	} else {
+		r.n++
+	}
+	l := &r.lines[i]
+	l.lineNo, l.offset = lineNo, offset
+	l.text = append(l.text[:0], text...)
+}
+
+// drain calls fn with the lines held, oldest first, and empties the ring.
+func (r *contextRing) drain(fn func(l *contextLine)) {
+	for k := range r.n {
+		fn(&r.lines[(r.start+k)%len(r.lines)])
+	}
+	r.start, r.n = 0, 0
+}
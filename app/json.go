@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// The --json messages follow ripgrep's JSON Lines format, so that tools reading
+// `rg --json` can read ours. Each file with a selected line gets a begin message, match
+// and context messages for the lines printed, and an end message with its statistics;
+// a summary message closes the output.
+
+// jsonMessage is one line of --json output.
+type jsonMessage struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// jsonText is a path or text in a message: {"text": ...} when it is valid UTF-8,
+// otherwise {"bytes": ...} holding it base64-encoded.
+type jsonText []byte
+
+func (t jsonText) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(t) {
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{string(t)})
+	}
+	return json.Marshal(struct {
+		Bytes []byte `json:"bytes"`
+	}{t})
+}
+
+type jsonBegin struct {
+	Path jsonText `json:"path"`
+}
+
+// jsonLine is the data of a match or context message.
+type jsonLine struct {
+	Path           jsonText       `json:"path"`
+	Lines          jsonText       `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int            `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+// jsonSubmatch is a match within a line, with offsets relative to the line. Groups is
+// our addition to ripgrep's format.
+type jsonSubmatch struct {
+	Match  jsonText    `json:"match"`
+	Start  int         `json:"start"`
+	End    int         `json:"end"`
+	Groups []jsonGroup `json:"groups,omitempty"`
+}
+
+// jsonGroup is a capture group that took part in a match.
+type jsonGroup struct {
+	Group int      `json:"group"`
+	Name  string   `json:"name,omitempty"`
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonEnd struct {
+	Path         jsonText  `json:"path"`
+	BinaryOffset *int      `json:"binary_offset"`
+	Stats        jsonStats `json:"stats"`
+}
+
+type jsonSummary struct {
+	ElapsedTotal jsonDuration `json:"elapsed_total"`
+	Stats        jsonStats    `json:"stats"`
+}
+
+type jsonStats struct {
+	Elapsed           jsonDuration `json:"elapsed"`
+	Searches          int          `json:"searches"`
+	SearchesWithMatch int          `json:"searches_with_match"`
+	BytesSearched     int          `json:"bytes_searched"`
+	BytesPrinted      int          `json:"bytes_printed"`
+	MatchedLines      int          `json:"matched_lines"`
+	Matches           int          `json:"matches"`
+}
+
+// add accumulates the counts of o into st.
+func (st *jsonStats) add(o jsonStats) {
+	st.Searches += o.Searches
+	st.SearchesWithMatch += o.SearchesWithMatch
+	st.BytesSearched += o.BytesSearched
+	st.BytesPrinted += o.BytesPrinted
+	st.MatchedLines += o.MatchedLines
+	st.Matches += o.Matches
+}
+
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Secs  int64  `json:"secs"`
+		Nanos int64  `json:"nanos"`
+		Human string `json:"human"`
+	}{
+		Secs:  int64(time.Duration(d) / time.Second),
+		Nanos: int64(time.Duration(d) % time.Second),
+		Human: fmt.Sprintf("%.6fs", time.Duration(d).Seconds()),
+	})
+}
+
+// jsonReport keeps the statistics of a --json search.
+type jsonReport struct {
+	start     time.Time // Of the whole search
+	fileStart time.Time
+	begun     bool // Whether the current file's begin message was written
+	file      jsonStats
+	total     jsonStats
+}
+
+// startJSONFile resets the statistics for a new file.
+func (s *searcher) startJSONFile() {
+	now := time.Now()
+	if s.report.start.IsZero() {
+		s.report.start = now
+	}
+	s.report.fileStart = now
+	s.report.begun = false
+	s.report.file = jsonStats{}
+}
+
+// writeJSONLine writes a match or context message for raw, the line at offset in name,
+// with the submatch indices of its matches in locs.
+func (s *searcher) writeJSONLine(kind, name string, lineNo, offset int, raw []byte, locs [][]int) {
+	if !s.report.begun {
+		s.report.begun = true
+		s.writeJSON("begin", jsonBegin{Path: jsonText(name)})
+	}
+
+	names := s.match.SubexpNames()
+	submatches := []jsonSubmatch{}
+	for _, loc := range locs {
+		m := jsonSubmatch{Match: jsonText(raw[loc[0]:loc[1]]), Start: loc[0], End: loc[1]}
+		for g := 1; 2*g < len(loc); g++ {
+			if start, end := loc[2*g], loc[2*g+1]; start >= 0 {
+				m.Groups = append(m.Groups, jsonGroup{Group: g, Name: names[g], Match: jsonText(raw[start:end]), Start: start, End: end})
+			}
+		}
+		submatches = append(submatches, m)
+	}
+	if kind == "match" {
+		s.report.file.MatchedLines++
+		s.report.file.Matches += len(submatches)
+	}
+
+	s.writeJSON(kind, jsonLine{
+		Path:           jsonText(name),
+		Lines:          jsonText(raw),
+		LineNumber:     lineNo,
+		AbsoluteOffset: offset,
+		Submatches:     submatches,
+	})
+}
+
+// endJSONFile writes the end message of name, if it had a begin message, and adds the
+// file's statistics to the total.
+func (s *searcher) endJSONFile(name string, bytesSearched int) {
+	st := &s.report.file
+	st.Searches = 1
+	if st.MatchedLines > 0 {
+		st.SearchesWithMatch = 1
+	}
+	st.BytesSearched = bytesSearched
+	st.Elapsed = jsonDuration(time.Since(s.report.fileStart))
+	if s.report.begun {
+		s.writeJSON("end", jsonEnd{Path: jsonText(name), Stats: *st})
+	}
+	s.report.total.add(*st)
+}
+
+// writeJSONSummary writes the summary message closing the output.
+func (s *searcher) writeJSONSummary() {
+	var elapsed time.Duration
+	if !s.report.start.IsZero() {
+		elapsed = time.Since(s.report.start)
+	}
+	s.report.total.Elapsed = jsonDuration(elapsed)
+	s.writeJSON("summary", jsonSummary{ElapsedTotal: jsonDuration(elapsed), Stats: s.report.total})
+}
+
+// writeJSON writes a message of the given type, counting its bytes as printed.
+func (s *searcher) writeJSON(kind string, data any) {
+	// The messages only hold types that always marshal
+	b, _ := json.Marshal(jsonMessage{Type: kind, Data: data})
+	s.report.file.BytesPrinted += len(b) + 1
+	s.write(append(b, '\n'))
+}
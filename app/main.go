@@ -2,11 +2,13 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/codecrafters-io/grep-starter-go/app/matcher"
 	"github.com/codecrafters-io/grep-starter-go/app/parser"
@@ -15,68 +17,62 @@ import (
 
 // Usage: echo <input_text> | your_program.sh -E <pattern>
 func main() {
-	// Parse flags: support -E <pattern> [paths...] and optional -r for recursive directory search.
-	recursive, pattern, paths, err := parseArgs(os.Args[1:])
+	// Parse flags: support -E/-e <pattern> and -f <file> [paths...], optional -r for recursive
+	// directory search, -i to ignore case, -u for UTF-8 aware matching and -F for fixed strings.
+	// Patterns are basic regular expressions unless -E is given.
+	opts, err := parseArgs(os.Args[1:])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(2)
 	}
+	paths := opts.paths
 
-	// Compile regex once.
-	re, err := compilePattern(pattern)
+	// Compile all patterns once into a single matcher.
+	match, err := newMatcher(opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(2)
 	}
 
+	s := &searcher{opts: opts, match: match, out: os.Stdout}
+	s.colors = outputPalette(opts.color, isTerminal(os.Stdout), os.Getenv)
+	if opts.onlyGroup != "" {
+		if s.group, err = groupIndex(match.SubexpNames(), opts.onlyGroup); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	// process searches one file; with -q the first selected line settles the exit status,
+	// so the search stops there.
+	process := func(path string, alwaysPrefix bool) (bool, error) {
+		matched, err := s.processFile(path, alwaysPrefix)
+		if matched && opts.quiet {
+			os.Exit(0)
+		}
+		return matched, err
+	}
+
 	// If -r is set, we expect at least one path (directory or file) and always print with filename prefix.
-	if recursive {
+	if opts.recursive {
 		if len(paths) == 0 {
-			fmt.Fprintf(os.Stderr, "usage: mygrep -r -E <pattern> <path> [<path> ...]\n")
+			fmt.Fprintf(os.Stderr, "usage: mygrep -r [options] <pattern> <path> [<path> ...]\n")
 			os.Exit(2)
 		}
-		foundAny := false
-		for _, p := range paths {
-			info, statErr := os.Stat(p)
-			if statErr != nil {
-				fmt.Fprintf(os.Stderr, "error: stat path %s: %v\n", p, statErr)
-				os.Exit(2)
-			}
-			if info.IsDir() {
-				walkErr := filepath.WalkDir(p, func(path string, d fs.DirEntry, walkErr error) error {
-					if walkErr != nil {
-						return walkErr
-					}
-					if d.IsDir() {
-						return nil
-					}
-					// Only process regular files
-					if !d.Type().IsRegular() {
-						return nil
-					}
-					matched, procErr := processFile(path, re, true)
-					if procErr != nil {
-						return procErr
-					}
-					if matched {
-						foundAny = true
-					}
-					return nil
-				})
-				if walkErr != nil {
-					fmt.Fprintf(os.Stderr, "error: walk path %s: %v\n", p, walkErr)
-					os.Exit(2)
-				}
-			} else {
-				matched, procErr := processFile(p, re, true)
-				if procErr != nil {
-					fmt.Fprintf(os.Stderr, "error: process file %s: %v\n", p, procErr)
-					os.Exit(2)
-				}
-				if matched {
-					foundAny = true
-				}
-			}
+		// Files are searched in parallel, with the output of each written in one piece
+		failed := false
+		foundAny := s.searchParallel(paths, func(err error) {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			failed = true
+		})
+		if foundAny && opts.quiet {
+			os.Exit(0)
+		}
+		if opts.json {
+			s.writeJSONSummary()
+		}
+		if failed {
+			os.Exit(2)
 		}
 		if !foundAny {
 			os.Exit(1)
@@ -84,31 +80,31 @@ func main() {
 		return
 	}
 
-	if len(paths) > 0 {
-		multi := len(paths) > 1
-		foundAny := false
-		for _, fname := range paths {
-			matched, err := processFile(fname, re, multi)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error: process file %s: %v\n", fname, err)
-				os.Exit(2)
-			}
-			if matched {
-				foundAny = true
-			}
+	// Without paths, read stdin
+	if len(paths) == 0 {
+		paths = []string{stdinPath}
+	}
+
+	multi := len(paths) > 1
+	foundAny := false
+	failed := false // Like GNU grep, an error only settles the exit status once all files are searched
+	for _, fname := range paths {
+		matched, err := process(fname, multi)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: process file %s: %v\n", fname, err)
+			failed = true
 		}
-		if !foundAny {
-			os.Exit(1)
+		if matched {
+			foundAny = true
 		}
-		return
 	}
-
-	line, rerr := io.ReadAll(os.Stdin)
-	if rerr != nil {
-		fmt.Fprintf(os.Stderr, "error: read input text: %v\n", rerr)
+	if opts.json {
+		s.writeJSONSummary()
+	}
+	if failed {
 		os.Exit(2)
 	}
-	if !matcher.Match(line, re) {
+	if !foundAny {
 		os.Exit(1)
 	}
 }
@@ -117,7 +113,161 @@ func matchWithCompiled(line []byte, re *regex.CompiledRegex) bool {
 	if re == nil {
 		return false
 	}
-	return matcher.Match(line, re)
+	return newLineMatcher(re).Match(line)
+}
+
+// lineMatcher tells whether a line matches the patterns, and where.
+type lineMatcher interface {
+	Match(line []byte) bool
+	// FindAll returns the submatch indices of the successive non-overlapping matches in
+	// line, laid out as in matcher.FindAllSubmatchIndex.
+	FindAll(line []byte) [][]int
+	// SubexpNames returns the name of each group, "" if unnamed; group 0 is the whole match.
+	SubexpNames() []string
+	// Clone returns a matcher for the same patterns to use in another goroutine.
+	Clone() lineMatcher
+}
+
+// newMatcher builds the line matcher for the patterns in opts: an Aho-Corasick automaton
+// with -F, otherwise a compiled regex.
+func newMatcher(opts *options) (lineMatcher, error) {
+	if opts.fixedStrings {
+		patterns := make([][]byte, len(opts.patterns))
+		for i, p := range opts.patterns {
+			patterns[i] = []byte(p)
+		}
+		m := &fixedMatcher{ac: matcher.NewAhoCorasick(patterns, opts.ignoreCase)}
+		switch {
+		case opts.lineRegexp:
+			m.accept = func(line []byte, start, end int) bool {
+				return start == 0 && end == len(line)
+			}
+		case opts.wordRegexp:
+			m.accept = isWholeWord
+		}
+		return m, nil
+	}
+
+	re, err := compilePatterns(opts.patterns, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newLineMatcher(re), nil
+}
+
+// fixedMatcher matches fixed strings, keeping only the occurrences accept holds for if
+// it is not nil.
+type fixedMatcher struct {
+	ac     *matcher.AhoCorasick
+	accept func(line []byte, start, end int) bool
+}
+
+func (m *fixedMatcher) Match(line []byte) bool {
+	if m.accept == nil {
+		return m.ac.Match(line)
+	}
+	found := false
+	m.ac.Each(line, func(start, end int) bool {
+		found = m.accept(line, start, end)
+		return !found
+	})
+	return found
+}
+
+// FindAll follows the empty match rules of matcher.FindAllSubmatchIndex.
+func (m *fixedMatcher) FindAll(line []byte) [][]int {
+	var accept func(start, end int) bool
+	if m.accept != nil {
+		accept = func(start, end int) bool {
+			return m.accept(line, start, end)
+		}
+	}
+
+	var all [][]int
+	prevEnd := -1
+	for pos := 0; pos <= len(line); {
+		start, end, ok := m.ac.IndexFunc(line, pos, accept)
+		if !ok {
+			break
+		}
+		if start == end {
+			pos = end + 1
+			if start == prevEnd {
+				continue
+			}
+		} else {
+			pos = end
+		}
+		prevEnd = end
+		all = append(all, []int{start, end})
+	}
+	return all
+}
+
+func (m *fixedMatcher) SubexpNames() []string {
+	return []string{""}
+}
+
+// Clone returns m, as the automaton is only read once built.
+func (m *fixedMatcher) Clone() lineMatcher {
+	return m
+}
+
+// isWholeWord reports whether line[start:end] is neither preceded nor followed by a word
+// character, as -w requires.
+func isWholeWord(line []byte, start, end int) bool {
+	return (start == 0 || !parser.WordMatcher.Match(line[start-1])) &&
+		(end == len(line) || !parser.WordMatcher.Match(line[end]))
+}
+
+// regexMatcher matches a compiled regex, using the fastest engine able to tell whether a
+// line matches: the lazy DFA when the pattern allows it, otherwise the NFA matcher.
+type regexMatcher struct {
+	re  *regex.CompiledRegex
+	dfa *matcher.DFA
+}
+
+func newLineMatcher(re *regex.CompiledRegex) *regexMatcher {
+	m := &regexMatcher{re: re}
+	if dfa, err := matcher.NewDFA(re); err == nil {
+		m.dfa = dfa
+	}
+	return m
+}
+
+func (m *regexMatcher) Match(line []byte) bool {
+	if m.dfa != nil {
+		return m.dfa.Match(line)
+	}
+	return matcher.Match(line, m.re)
+}
+
+func (m *regexMatcher) FindAll(line []byte) [][]int {
+	return matcher.FindAllSubmatchIndex(line, m.re, -1)
+}
+
+func (m *regexMatcher) SubexpNames() []string {
+	return m.re.SubexpNames()
+}
+
+// Clone shares the compiled regex but gives the copy its own DFA.
+func (m *regexMatcher) Clone() lineMatcher {
+	return newLineMatcher(m.re)
+}
+
+// groupIndex resolves the --only-group argument, a group number or name, to the index
+// of the group in names.
+func groupIndex(names []string, group string) (int, error) {
+	if n, err := strconv.Atoi(group); err == nil {
+		if n < 0 || n >= len(names) {
+			return 0, fmt.Errorf("no group %d in pattern", n)
+		}
+		return n, nil
+	}
+	if i := slices.Index(names, group); i > 0 {
+		return i, nil
+	}
+	return 0, fmt.Errorf("no group named %q in pattern", group)
 }
 
 // matchLine keeps the original test-facing API: compile pattern, then match once.
@@ -125,21 +275,50 @@ func matchLine(line []byte, pattern string) (bool, error) {
 	if len(line) == 0 && pattern == "" {
 		return false, nil
 	}
-	re, err := compilePattern(pattern)
+	re, err := compilePattern(pattern, &options{extended: true})
 	if err != nil {
 		return false, err
 	}
 	return matchWithCompiled(line, re), nil
 }
 
-// compilePattern parses and compiles a pattern once.
-func compilePattern(pattern string) (*regex.CompiledRegex, error) {
-	p := parser.New(pattern)
-	regexNode, err := p.Parse()
-	if err != nil {
-		return nil, fmt.Errorf("parse pattern: %w", err)
+// compilePattern parses and compiles a pattern once, honouring the -E, -i and -u flags in opts.
+func compilePattern(pattern string, opts *options) (*regex.CompiledRegex, error) {
+	return compilePatterns([]string{pattern}, opts)
+}
+
+// compilePatterns compiles patterns into one regex matching lines that match any of them.
+func compilePatterns(patterns []string, opts *options) (*regex.CompiledRegex, error) {
+	nodes := make([]*parser.RegexNode, 0, len(patterns))
+	for _, pattern := range patterns {
+		p := parser.New(pattern)
+		if opts.unicode {
+			p.WithUnicode()
+		}
+		if !opts.extended {
+			p.WithBasic()
+		}
+		regexNode, err := p.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("parse pattern %q: %w", pattern, err)
+		}
+		switch {
+		case opts.lineRegexp:
+			regexNode = wholeLine(regexNode)
+		case opts.wordRegexp:
+			regexNode = wholeWord(regexNode)
+		}
+		nodes = append(nodes, regexNode)
+	}
+
+	compileOpts := regex.Options{CaseInsensitive: opts.ignoreCase, UTF8: opts.unicode}
+	var re *regex.CompiledRegex
+	var err error
+	if len(nodes) == 1 {
+		re, err = regex.CompileWithOptions(nodes[0], compileOpts)
+	} else {
+		re, err = regex.CompileMulti(nodes, compileOpts)
 	}
-	re, err := regex.Compile(regexNode)
 	if err != nil {
 		return nil, fmt.Errorf("compile pattern: %w", err)
 	}
@@ -149,65 +328,578 @@ func compilePattern(pattern string) (*regex.CompiledRegex, error) {
 	return re, nil
 }
 
-// processFile scans a file line-by-line and prints matches. If alwaysPrefix is true, prefix filename for each matched line.
+// stdinPath is the path operand standing for standard input.
+const stdinPath = "-"
+
+// searcher runs the compiled patterns over inputs and prints the selected lines.
+type searcher struct {
+	opts   *options
+	match  lineMatcher
+	group  int // Group printed by -o
+	out    io.Writer
+	buf    []byte  // Reused to format output lines
+	colors palette // Zero for plain output
+	report jsonReport
+
+	printedGroup bool // Whether a context group was printed, so the next one needs a separator
+}
+
+// wholeLine wraps a parsed pattern so that it only matches a whole line, for -x.
+func wholeLine(root *parser.RegexNode) *parser.RegexNode {
+	return &parser.RegexNode{Type: parser.NodeTypeGroup, Capturing: true, Children: []*parser.RegexNode{
+		parser.NewCaretAnchor(),
+		parser.NewGroup(root.Children),
+		parser.NewDollarAnchor(),
+	}}
+}
+
+// wholeWord wraps a parsed pattern so that a match is neither preceded nor followed by a
+// word character, for -w. Unlike \b this also holds for matches at non-word characters,
+// and the search moves on to other matches when the first one is not a whole word. The
+// assertions are plain ones the DFA runs, unlike lookarounds.
+func wholeWord(root *parser.RegexNode) *parser.RegexNode {
+	return &parser.RegexNode{Type: parser.NodeTypeGroup, Capturing: true, Children: []*parser.RegexNode{
+		parser.NewNoWordBefore(),
+		parser.NewGroup(root.Children),
+		parser.NewNoWordAfter(),
+	}}
+}
+
+// processFile scans a file, or stdin for "-", line-by-line and prints the lines accepted by match. If alwaysPrefix is true, prefix filename for each matched line.
 // Returns whether any match was found in this file.
-func processFile(path string, re *regex.CompiledRegex, alwaysPrefix bool) (bool, error) {
+func (s *searcher) processFile(path string, alwaysPrefix bool) (bool, error) {
+	if path == stdinPath {
+		return s.processReader(os.Stdin, s.opts.label, alwaysPrefix)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
 		return false, fmt.Errorf("open file: %w", err)
 	}
 	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
+	return s.processReader(f, path, alwaysPrefix)
+}
+
+// processReader scans r line-by-line and prints the selected lines: those accepted by match,
+// or with -v the others, prefixed with name if alwaysPrefix is true. Returns whether any
+// line was selected, or with -L whether name was listed.
+func (s *searcher) processReader(r io.Reader, name string, alwaysPrefix bool) (bool, error) {
+	scanner := bufio.NewScanner(r)
 	// Increase the buffer limit to handle long lines (up to 10MB)
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, 10*1024*1024)
+	scanner.Split(scanLines)
 
-	found := false
-	for scanner.Scan() {
-		text := scanner.Text()
-		if matchWithCompiled([]byte(text), re) {
-			found = true
-			if alwaysPrefix {
-				fmt.Printf("%s:%s\n", path, text)
-			} else {
-				fmt.Println(text)
+	// --vimgrep rows always name the file
+	alwaysPrefix = alwaysPrefix || s.opts.vimgrep
+	// Match spans are only needed to print matches or their columns
+	needSpans := !s.opts.invert && (s.opts.onlyMatching || s.opts.column)
+
+	// -q, -l and -L only need to know whether some line is selected, and -c how many.
+	// --json replaces all of them but -q.
+	listOnly := s.opts.quiet || !s.opts.json && (s.opts.filesWithMatches || s.opts.filesWithoutMatch)
+	printLines := !listOnly && (s.opts.json || !s.opts.count)
+	limit := -1
+	if s.opts.hasMaxCount {
+		limit = s.opts.maxCount
+	}
+	if listOnly && limit != 0 {
+		limit = 1
+	}
+
+	// Context is printed around whole lines only
+	var ring *contextRing
+	withContext := printLines && (s.opts.before > 0 || s.opts.after > 0) &&
+		(s.opts.json || !s.opts.onlyMatching && !s.opts.vimgrep)
+	if withContext {
+		ring = newContextRing(s.opts.before)
+	}
+	lastPrinted := 0 // Number of the last line printed from r, 0 if none
+	afterLeft := 0   // After-context lines still to print
+	printContext := func(lineNo, offset int, raw []byte) {
+		if s.opts.json {
+			// Context lines hold matches under -v and past the -m count, which ripgrep reports
+			s.writeJSONLine("context", name, lineNo, offset, raw, s.match.FindAll(trimEOL(raw)))
+		} else {
+			s.printLine(name, alwaysPrefix, '-', lineNo, 0, offset, trimEOL(raw))
+		}
+		lastPrinted = lineNo
+	}
+
+	if s.opts.json {
+		s.startJSONFile()
+	}
+
+	selected := 0
+	lineNo, offset := 0, 0
+	// With -m 0 no line is read, though -c still prints its count and -L the name
+	for (limit < 0 || selected < limit || afterLeft > 0) && scanner.Scan() {
+		raw := scanner.Bytes()
+		line := trimEOL(raw)
+		lineNo++
+		lineStart := offset
+		offset += len(raw)
+
+		if limit >= 0 && selected >= limit {
+			// Past the -m count only the trailing context is printed, whether it matches or not
+			afterLeft--
+			printContext(lineNo, lineStart, raw)
+			continue
+		}
+
+		if s.match.Match(line) == s.opts.invert {
+			switch {
+			case afterLeft > 0:
+				afterLeft--
+				printContext(lineNo, lineStart, raw)
+			case ring != nil:
+				ring.push(lineNo, lineStart, raw)
 			}
+			continue
+		}
+		selected++
+		if !printLines {
+			continue
+		}
+
+		if withContext {
+			ring.drain(func(l *contextLine) {
+				s.separate(lastPrinted, l.lineNo)
+				printContext(l.lineNo, l.offset, l.text)
+			})
+			s.separate(lastPrinted, lineNo)
+			lastPrinted = lineNo
+			afterLeft = s.opts.after
+		}
+
+		var locs [][]int
+		if needSpans {
+			locs = s.match.FindAll(line)
+		}
+		switch {
+		case s.opts.json:
+			if !s.opts.invert {
+				locs = s.match.FindAll(line)
+			}
+			s.writeJSONLine("match", name, lineNo, lineStart, raw, locs)
+		case s.opts.onlyMatching:
+			// Lines selected by -v hold no match to print
+			for _, loc := range locs {
+				start, end := loc[2*s.group], loc[2*s.group+1]
+				if start < end {
+					s.printLine(name, alwaysPrefix, ':', lineNo, start+1, lineStart+start, line[start:end])
+				}
+			}
+		case s.opts.vimgrep && len(locs) > 0:
+			for _, loc := range locs {
+				s.printLine(name, alwaysPrefix, ':', lineNo, loc[0]+1, lineStart, line)
+			}
+		default:
+			column := 0
+			if len(locs) > 0 {
+				column = locs[0][0] + 1
+			}
+			s.printLine(name, alwaysPrefix, ':', lineNo, column, lineStart, line)
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return found, fmt.Errorf("scan file: %w", err)
+		return selected > 0, fmt.Errorf("scan file: %w", err)
+	}
+
+	switch {
+	case s.opts.json:
+		s.endJSONFile(name, offset)
+	case s.opts.quiet:
+	case s.opts.filesWithMatches:
+		if selected > 0 {
+			s.printSummary(name, true, selected)
+		}
+	case s.opts.filesWithoutMatch:
+		if selected == 0 {
+			s.printSummary(name, true, selected)
+		}
+	case s.opts.count:
+		s.printSummary(name, alwaysPrefix, selected)
+	}
+	if s.opts.filesWithoutMatch && !s.opts.quiet && !s.opts.json {
+		// Success for -L is a listed file
+		return selected == 0, nil
 	}
-	return found, nil
+	return selected > 0, nil
 }
 
-// parseArgs parses supported CLI flags and returns (recursive, pattern, paths, error).
-func parseArgs(args []string) (bool, string, []string, error) {
-	recursive := false
-	pattern := ""
-	paths := []string{}
+// trimEOL returns raw without its line terminator, either \n or \r\n.
+func trimEOL(raw []byte) []byte {
+	return bytes.TrimSuffix(bytes.TrimSuffix(raw, []byte("\n")), []byte("\r"))
+}
+
+// scanLines is bufio.ScanLines keeping the line terminator, so that byte offsets can be
+// counted.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// separate prints the "--" line that sets a context group apart from the previous one
+// when lineNo does not follow lastPrinted, the last line printed from the same input.
+func (s *searcher) separate(lastPrinted, lineNo int) {
+	if s.printedGroup && !s.opts.json && (lastPrinted == 0 || lineNo != lastPrinted+1) {
+		b := appendColored(s.buf[:0], s.colors.separator, "--")
+		s.write(append(b, '\n'))
+	}
+	s.printedGroup = true
+}
+
+// printLine prints text on its own line after the enabled prefixes, each followed by sep:
+// name if alwaysPrefix is true, then with -n the line number, with --column the 1-based
+// column unless it is 0, and with -b the byte offset. With colours on, the matches in
+// text are highlighted.
+func (s *searcher) printLine(name string, alwaysPrefix bool, sep byte, lineNo, column, offset int, text []byte) {
+	c := &s.colors
+	sepText := []byte{sep}
+	b := s.buf[:0]
+	if alwaysPrefix {
+		b = appendColored(b, c.fileName, name)
+		b = appendColored(b, c.separator, sepText)
+	}
+	if s.opts.lineNumber {
+		b = appendColored(b, c.lineNumber, strconv.Itoa(lineNo))
+		b = appendColored(b, c.separator, sepText)
+	}
+	if s.opts.column && column > 0 {
+		b = appendColored(b, c.lineNumber, strconv.Itoa(column))
+		b = appendColored(b, c.separator, sepText)
+	}
+	if s.opts.byteOffset {
+		b = appendColored(b, c.byteOffset, strconv.Itoa(offset))
+		b = appendColored(b, c.separator, sepText)
+	}
+
+	matchColor := c.selectedMatch
+	if sep == '-' {
+		matchColor = c.contextMatch
+	}
+	switch {
+	case matchColor == "":
+		b = append(b, text...)
+	case s.opts.onlyMatching:
+		b = appendColored(b, matchColor, text)
+	default:
+		pos := 0
+		for _, loc := range s.match.FindAll(text) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			b = append(b, text[pos:loc[0]]...)
+			b = appendColored(b, matchColor, text[loc[0]:loc[1]])
+			pos = loc[1]
+		}
+		b = append(b, text[pos:]...)
+	}
+	s.write(append(b, '\n'))
+}
+
+// printSummary prints the line -l, -L or -c gives for a file: name, followed with -c by
+// count.
+func (s *searcher) printSummary(name string, alwaysPrefix bool, count int) {
+	b := s.buf[:0]
+	if alwaysPrefix {
+		b = appendColored(b, s.colors.fileName, name)
+	}
+	if s.opts.count && !s.opts.filesWithMatches && !s.opts.filesWithoutMatch {
+		if alwaysPrefix {
+			b = appendColored(b, s.colors.separator, ":")
+		}
+		b = strconv.AppendInt(b, int64(count), 10)
+	}
+	s.write(append(b, '\n'))
+}
+
+// write writes b, a line built in s.buf, keeping its storage for the next line.
+func (s *searcher) write(b []byte) {
+	s.out.Write(b)
+	s.buf = b
+}
+
+// options holds the parsed command-line flags.
+type options struct {
+	recursive         bool
+	ignoreCase        bool
+	unicode           bool
+	fixedStrings      bool
+	invert            bool   // -v: select non-matching lines
+	lineRegexp        bool   // -x: a match must span the whole line
+	wordRegexp        bool   // -w: a match must be a whole word
+	extended          bool   // -E: patterns are extended rather than basic regular expressions
+	onlyMatching      bool   // -o: print each match instead of the whole line
+	onlyGroup         string // --only-group: number or name of the group -o prints instead of the match
+	lineNumber        bool   // -n: prefix lines with their 1-based number
+	byteOffset        bool   // -b: prefix lines, or matches with -o, with their byte offset in the file
+	column            bool   // --column: prefix lines with the 1-based column of their first match
+	vimgrep           bool   // --vimgrep: print path:line:column:text for every match
+	json              bool   // --json: print ripgrep's JSON Lines messages instead of lines
+	after             int    // -A: context lines to print after each selected line
+	before            int    // -B: context lines to print before each selected line
+	count             bool   // -c: print the number of selected lines of each file
+	filesWithMatches  bool   // -l: print the names of files with a selected line
+	filesWithoutMatch bool   // -L: print the names of files without a selected line
+	quiet             bool   // -q: print nothing and exit at the first selected line
+	color             string // --color: when to colour the output, auto, always or never
+	jobs              int    // -j: number of files searched at once with -r, 0 for GOMAXPROCS
+	sortPath          bool   // --sort path: print the files found by -r in walk order
+	maxCount          int    // -m: stop reading a file after this many selected lines
+	hasMaxCount       bool   // Whether -m was given
+	label             string // Name shown for stdin when prefixes are printed
+	patterns          []string
+	paths             []string
+}
+
+const usage = "usage: mygrep [-r [-j <num>] [--sort path]] [-i] [-u] [-v] [-x | -w] [-c | -l | -L | -q] [-m <num>] [-A <num>] [-B <num>] [-C <num>] [-n] [-b] [--column | --vimgrep] [-o | --only-group <group>] [--color[=<when>] | --json] [-E | -F | -G] [--label <name>] {-e <pattern> | -f <file>}... [<path> ...]\n" +
+	"       mygrep [-r [-j <num>] [--sort path]] [-i] [-u] [-v] [-x | -w] [-c | -l | -L | -q] [-m <num>] [-A <num>] [-B <num>] [-C <num>] [-n] [-b] [--column | --vimgrep] [-o | --only-group <group>] [--color[=<when>] | --json] [-E | -F | -G] [--label <name>] <pattern> [<path> ...]"
+
+// parseArgs parses supported CLI flags.
+func parseArgs(args []string) (*options, error) {
+	opts := &options{paths: []string{}, label: "(standard input)", color: "auto"}
+	sawPattern := false // An empty -f file gives no patterns, which matches nothing
 
 	i := 0
 	for i < len(args) {
 		a := args[i]
 		switch a {
 		case "-r":
-			recursive = true
+			opts.recursive = true
+			i++
+		case "-i", "--ignore-case":
+			opts.ignoreCase = true
+			i++
+		case "-u", "--unicode":
+			opts.unicode = true
+			i++
+		case "-F", "--fixed-strings":
+			opts.fixedStrings = true
+			i++
+		case "-v", "--invert-match":
+			opts.invert = true
+			i++
+		case "-x", "--line-regexp":
+			opts.lineRegexp = true
+			i++
+		case "-w", "--word-regexp":
+			opts.wordRegexp = true
+			i++
+		case "-o", "--only-matching":
+			opts.onlyMatching = true
+			i++
+		case "--only-group":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf(usage)
+			}
+			opts.onlyMatching = true
+			opts.onlyGroup = args[i+1]
+			i += 2
+		case "-n", "--line-number":
+			opts.lineNumber = true
 			i++
-		case "-E":
+		case "-b", "--byte-offset":
+			opts.byteOffset = true
+			i++
+		case "--column":
+			opts.lineNumber = true
+			opts.column = true
+			i++
+		case "--vimgrep":
+			opts.lineNumber = true
+			opts.column = true
+			opts.vimgrep = true
+			i++
+		case "-c", "--count":
+			opts.count = true
+			i++
+		case "-l", "--files-with-matches":
+			opts.filesWithMatches = true
+			i++
+		case "-L", "--files-without-match":
+			opts.filesWithoutMatch = true
+			i++
+		case "-q", "--quiet", "--silent":
+			opts.quiet = true
+			i++
+		case "-A", "--after-context", "-B", "--before-context", "-C", "--context", "-m", "--max-count", "-j", "--threads":
 			if i+1 >= len(args) {
-				return false, "", nil, fmt.Errorf("usage: mygrep [-r] -E <pattern> [<path> ...]")
+				return nil, fmt.Errorf(usage)
+			}
+			if err := setNumberFlag(opts, a, args[i+1]); err != nil {
+				return nil, err
 			}
-			pattern = args[i+1]
+			i += 2
+		case "--sort":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf(usage)
+			}
+			if err := setSort(opts, args[i+1]); err != nil {
+				return nil, err
+			}
+			i += 2
+		case "--json":
+			opts.json = true
+			i++
+		case "--color", "--colour":
+			opts.color = "auto"
+			i++
+		case "--label":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf(usage)
+			}
+			opts.label = args[i+1]
+			i += 2
+		case "-G", "--basic-regexp":
+			opts.extended = false
+			i++
+		case "-E", "--extended-regexp":
+			opts.extended = true
+			i++
+		case "-e", "--regexp":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf(usage)
+			}
+			opts.patterns = append(opts.patterns, args[i+1])
+			sawPattern = true
+			i += 2
+		case "-f":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf(usage)
+			}
+			patterns, err := readPatternFile(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			opts.patterns = append(opts.patterns, patterns...)
+			sawPattern = true
 			i += 2
 		default:
-			paths = append(paths, a)
+			if label, ok := strings.CutPrefix(a, "--label="); ok {
+				opts.label = label
+			} else if group, ok := strings.CutPrefix(a, "--only-group="); ok {
+				opts.onlyMatching = true
+				opts.onlyGroup = group
+			} else if sort, ok := strings.CutPrefix(a, "--sort="); ok {
+				if err := setSort(opts, sort); err != nil {
+					return nil, err
+				}
+			} else if when, ok := cutColorFlag(a); ok {
+				if when != "auto" && when != "always" && when != "never" {
+					return nil, fmt.Errorf("invalid argument %q for --color: want auto, always or never", when)
+				}
+				opts.color = when
+			} else if flag, value, ok := cutNumberFlag(a); ok {
+				if err := setNumberFlag(opts, flag, value); err != nil {
+					return nil, err
+				}
+			} else {
+				opts.paths = append(opts.paths, a)
+			}
 			i++
 		}
 	}
 
-	if pattern == "" {
-		return false, "", nil, fmt.Errorf("usage: mygrep [-r] -E <pattern> [<path> ...]")
+	// Without -e or -f the first operand is the pattern
+	if !sawPattern && len(opts.paths) > 0 {
+		opts.patterns = append(opts.patterns, opts.paths[0])
+		opts.paths = opts.paths[1:]
+		sawPattern = true
+	}
+	if !sawPattern {
+		return nil, fmt.Errorf(usage)
+	}
+	return opts, nil
+}
+
+// cutColorFlag returns the value of a --color=WHEN or --colour=WHEN flag.
+func cutColorFlag(a string) (when string, ok bool) {
+	if when, ok = strings.CutPrefix(a, "--color="); ok {
+		return when, true
+	}
+	return strings.CutPrefix(a, "--colour=")
+}
+
+// cutNumberFlag splits a flag taking a count with the count attached, such as -A3,
+// -m5, -j8 or --context=3, into the flag and the count.
+func cutNumberFlag(a string) (flag, value string, ok bool) {
+	if flag, value, ok = strings.Cut(a, "="); ok {
+		switch flag {
+		case "--after-context", "--before-context", "--context", "--max-count", "--threads":
+			return flag, value, true
+		}
+		return "", "", false
+	}
+	if len(a) > 2 && strings.Contains("ABCjm", a[1:2]) && a[0] == '-' {
+		return a[:2], a[2:], true
+	}
+	return "", "", false
+}
+
+// setNumberFlag sets the count given value for a -A, -B, -C, -j or -m flag.
+func setNumberFlag(opts *options, flag, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%s: invalid count argument: %q", flag, value)
+	}
+	switch flag {
+	case "-A", "--after-context":
+		opts.after = n
+	case "-B", "--before-context":
+		opts.before = n
+	case "-m", "--max-count":
+		opts.maxCount, opts.hasMaxCount = n, true
+	case "-j", "--threads":
+		opts.jobs = n
+	default:
+		opts.after, opts.before = n, n
+	}
+	return nil
+}
+
+// setSort sets the order of -r output from a --sort value, path or none.
+func setSort(opts *options, value string) error {
+	switch value {
+	case "path":
+		opts.sortPath = true
+	case "none":
+		opts.sortPath = false
+	default:
+		return fmt.Errorf("invalid argument %q for --sort: want path or none", value)
+	}
+	return nil
+}
+
+// readPatternFile reads one pattern per line from path, or from stdin if path is "-".
+func readPatternFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open pattern file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		patterns = append(patterns, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read pattern file: %w", err)
 	}
-	return recursive, pattern, paths, nil
+	return patterns, nil
 }
@@ -1,6 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -237,3 +247,1143 @@ func Test_match_backreference(t *testing.T) {
 		run(t, tt.line, tt.pattern, tt.expected)
 	}
 }
+
+func Test_match_bounded_repetition(t *testing.T) {
+	tests := []testcase{
+		{"2024-01-31", "^\\d{4}-\\d{2}-\\d{2}$", true},
+		{"24-01-31", "^\\d{4}-\\d{2}-\\d{2}$", false},
+		{"aaa", "^a{3}$", true},
+		{"aa", "^a{3}$", false},
+		{"aaaa", "^a{3}$", false},
+		{"a", "^a{1,}$", true},
+		{"aaaaa", "^a{2,}$", true},
+		{"a", "^a{2,}$", false},
+		{"ab", "^a{1,3}b$", true},
+		{"aaab", "^a{1,3}b$", true},
+		{"aaaab", "^a{1,3}b$", false},
+		{"b", "^a{0,2}b$", true},
+		{"b", "^a{0}b$", true},
+		{"ab", "^a{0}b$", false},
+		{"abab", "^(ab){2}$", true},
+		{"ab", "^(ab){2}$", false},
+		{"cat dog", "^((cat|dog) ?){2}$", true},
+		{"x{y}", "x{y}", true},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_match_lazy_quantifiers(t *testing.T) {
+	tests := []testcase{
+		{`say "hi"`, `".*?"`, true},
+		{"aaab", "^a+?b$", true},
+		{"b", "^a*?b$", true},
+		{"ab", "^a??b$", true},
+		{"aab", "^a{1,2}?b$", true},
+		{"aaab", "^a{1,2}?b$", false},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_match_named_and_non_capturing_groups(t *testing.T) {
+	tests := []testcase{
+		{"abab", "^(?:ab)+$", true},
+		{"cat and cat", "(?P<animal>\\w+) and \\k<animal>", true},
+		{"cat and dog", "(?P<animal>\\w+) and \\k<animal>", false},
+		{"cat and cat", "(?<animal>\\w+) and \\1", true},
+		{"x-y y", "(?:x)-(y) \\1", true},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_match_lookaround(t *testing.T) {
+	tests := []testcase{
+		{"v1.2.3", "\\d+\\.\\d+\\.\\d+(?!-beta)", true},
+		{"v1.2.3-beta", "^v\\d+\\.\\d+\\.\\d+(?!-beta)$", false},
+		{"v1.23-beta", "v\\d+\\.\\d+(?!-beta)", true}, // backs off to v1.2
+		{"v1.23-beta", "v\\d+\\.\\d+(?![-\\d])", false},
+		{"foobar", "foo(?=bar)", true},
+		{"foobaz", "foo(?=bar)", false},
+		{"price: $100", "(?<=\\$)\\d+", true},
+		{"price: 100", "(?<=\\$)\\d+", false},
+		{"xb", "(?<!a)b", true},
+		{"ab", "(?<!a)b", false},
+		{"aaab", "(?<=a{2,}|c)b", true},
+		{"cab", "(?<=a{2,}|c)b", false},
+		{"quux", "(?<=q\\w*)x", true},
+		{"abc", "^(?=a)(?<=^)abc", true},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_match_word_boundary(t *testing.T) {
+	tests := []testcase{
+		{"foo bar", "\\bbar\\b", true},
+		{"foobar", "\\bbar\\b", false},
+		{"foobar", "\\Bbar\\b", true},
+		{"my_id = 1", "\\bmy_id\\b", true},
+		{"my_ident", "\\bmy_id\\b", false},
+		{"", "\\b", false},
+		{"", "\\B", true},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_match_shorthand_classes(t *testing.T) {
+	tests := []testcase{
+		{"a b", "a\\sb", true},
+		{"a\tb", "a\\sb", true},
+		{"asb", "a\\s+b", false},
+		{"ssss", "^\\s+$", false},
+		{"abc", "^\\S+$", true},
+		{"a c", "^\\S+$", false},
+		{"abc", "^\\D+$", true},
+		{"a1c", "^\\D+$", false},
+		{"a-c", "a\\Wc", true},
+		{"a_c", "a\\Wc", false},
+		{"a\tb", "a\\hb", true},
+		{"a\nb", "a\\hb", false},
+		{"a\rb", "a\\vb", true},
+		{"a\tb", "a\\tb", true},
+		{"A", "\\x41", true},
+		{"\b1", "\\0101", true}, // \010 followed by a literal 1
+		{"a\x00b", "a\\0b", true},
+		{"a b", "a[\\s]b", true},
+		{"a1b", "a[^\\S]b", false},
+		{"a b", "a[^\\S]b", true},
+		{"a b", "a[\\Sx]b", false},
+		{"a-b", "a[\\D\\d]b", true},
+		{"\x05", "[\\x00-\\x1f]", true},
+		{"a", "[\\x00-\\x1f]", false},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_match_posix_classes(t *testing.T) {
+	tests := []testcase{
+		{"abc", "^[[:alpha:]]+$", true},
+		{"ab1", "^[[:alpha:]]+$", false},
+		{"123", "^[[:digit:]]+$", true},
+		{"a1B", "^[[:alnum:]]+$", true},
+		{"a-b", "^[[:alnum:]]+$", false},
+		{"ABC", "^[[:upper:]]+$", true},
+		{"AbC", "^[[:upper:]]+$", false},
+		{"abc", "^[[:lower:]]+$", true},
+		{"a \tb", "a[[:space:]]+b", true},
+		{"a \tb", "a[[:blank:]]+b", true},
+		{"a\nb", "a[[:blank:]]b", false},
+		{"a,b", "a[[:punct:]]b", true},
+		{"a b", "a[[:punct:]]b", false},
+		{"a b", "a[[:print:]]b", true},
+		{"a b", "a[[:graph:]]b", false},
+		{"a\x01b", "a[[:cntrl:]]b", true},
+		{"0xBEEF", "^0x[[:xdigit:]]+$", true},
+		{"0xBEEG", "^0x[[:xdigit:]]+$", false},
+		{"foo_bar", "^[[:alpha:]_]+$", true},
+		{"x1", "^[^[:digit:]][[:digit:]]$", true},
+		{"11", "^[^[:digit:]][[:digit:]]$", false},
+		{"a-b", "a[[.-.]]b", true},
+		{"a=b", "a[[=a=][===]]b", true},
+		{"]", "[]]", true},
+		{"a]", "^[^]]]$", true},
+		{"]]", "^[^]]]$", false},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_match_ignore_case(t *testing.T) {
+	tests := []testcase{
+		{"HELLO", "hello", true},
+		{"Hello", "^h[a-f]llo$", true},
+		{"HXLLO", "^h[a-f]llo$", false},
+		{"ABC", "[^a-c]", false},
+		{"ABCd", "[^a-c]", true},
+		{"Cat and CAT", "(cat) and \\1", true},
+		{"cat and CAT", "(?-i)cat and CAT", true},
+		{"CAT", "(?-i)cat", false},
+		{"CAT", "(?-i:c)at", false},
+		{"cAT", "(?-i:c)at", true},
+		{"A1", "\\w\\d", true},
+		{"abc", "^[[:upper:]]+$", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line+"_"+tt.pattern, func(t *testing.T) {
+			re, err := compilePattern(tt.pattern, &options{ignoreCase: true, extended: true})
+			if err != nil {
+				t.Fatalf("Error compiling pattern: %v", err)
+			}
+			if result := matchWithCompiled([]byte(tt.line), re); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func Test_match_inline_case_flags(t *testing.T) {
+	tests := []testcase{
+		{"HELLO", "(?i)hello", true},
+		{"HELLO", "hello", false},
+		{"heLLO", "he(?i)llo", true},
+		{"HEllo", "he(?i)llo", false},
+		{"aBc", "a(?i:b)c", true},
+		{"aBC", "a(?i:b)c", false},
+		{"xY", "(x(?i)y)", true},
+		{"xyZ", "(x(?i)y)z", false},
+		{"ab AB", "(?i)(ab) \\1", true},
+		{"ab AB", "(ab) \\1", false},
+	}
+
+	for _, tt := range tests {
+		run(t, tt.line, tt.pattern, tt.expected)
+	}
+}
+
+func Test_parseArgs(t *testing.T) {
+	opts, err := parseArgs([]string{"-r", "-i", "-E", "a+", "dir1", "dir2"})
+	if err != nil {
+		t.Fatalf("parseArgs() error = %v", err)
+	}
+	if !opts.recursive || !opts.ignoreCase || len(opts.patterns) != 1 || opts.patterns[0] != "a+" {
+		t.Errorf("parseArgs() = %+v", opts)
+	}
+	if len(opts.paths) != 2 || opts.paths[0] != "dir1" || opts.paths[1] != "dir2" {
+		t.Errorf("parseArgs() paths = %v", opts.paths)
+	}
+
+	if _, err := parseArgs([]string{"-i"}); err == nil {
+		t.Errorf("parseArgs() without pattern: expected error")
+	}
+}
+
+func Test_parseArgs_multiplePatterns(t *testing.T) {
+	patternFile := filepath.Join(t.TempDir(), "patterns.txt")
+	if err := os.WriteFile(patternFile, []byte("timeout\r\nfail(ed|ure)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseArgs([]string{"-e", "error", "-f", patternFile, "-e", "panic", "app.log"})
+	if err != nil {
+		t.Fatalf("parseArgs() error = %v", err)
+	}
+	want := []string{"error", "timeout", "fail(ed|ure)", "panic"}
+	if !slices.Equal(opts.patterns, want) {
+		t.Errorf("parseArgs() patterns = %q, want %q", opts.patterns, want)
+	}
+	if !slices.Equal(opts.paths, []string{"app.log"}) {
+		t.Errorf("parseArgs() paths = %v", opts.paths)
+	}
+
+	if _, err := parseArgs([]string{"-f", filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Errorf("parseArgs() with missing pattern file: expected error")
+	}
+}
+
+func Test_match_multiple_patterns(t *testing.T) {
+	tests := []struct {
+		line     string
+		patterns []string
+		expected bool
+	}{
+		{"disk error", []string{"error", "timeout"}, true},
+		{"read timeout", []string{"error", "timeout"}, true},
+		{"all good", []string{"error", "timeout"}, false},
+		{"abc abc", []string{"^x", "(abc) \\1$"}, true},
+		{"abc abd", []string{"(abc) \\1", "(abd) \\1"}, false},
+		{"anything", []string{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line+"_"+strings.Join(tt.patterns, "|"), func(t *testing.T) {
+			re, err := compilePatterns(tt.patterns, &options{extended: true})
+			if err != nil {
+				t.Fatalf("Error compiling patterns: %v", err)
+			}
+			if result := matchWithCompiled([]byte(tt.line), re); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func Test_match_unicode(t *testing.T) {
+	tests := []testcase{
+		{"é", "^.$", true},
+		{"é", "^..$", false},
+		{"日本", "^\\S{2}$", true},
+		{"café", "^caf[é]$", true},
+		{"cafe\xcc\x81", "^caf[é]", false},
+		{"ééé", "^é{3}$", true},
+		{"ééé", "^é+$", true},
+		{"naïve", "^[a-zà-ÿ]+$", true},
+		{"λόγος", "^\\p{Greek}+$", true},
+		{"logos", "^\\p{Greek}+$", false},
+		{"Ωmega", "^\\p{Lu}", true},
+		{"ωmega", "^\\p{Lu}", false},
+		{"123", "^\\PL+$", true},
+		{"a\xffb", "^a.b$", true}, // an invalid byte is one character
+		{"a\xffb", "^a[^x]b$", true},
+		{"a\xffb", "^a\\p{L}b$", false},
+		{"(?<=é)x", "(?<=é)x", false},
+		{"éx", "(?<=é)x", true},
+		{"éx", "(?<=^.)x", true},
+		{"ÄRGER", "(?i)^ärger$", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line+"_"+tt.pattern, func(t *testing.T) {
+			re, err := compilePattern(tt.pattern, &options{unicode: true, extended: true})
+			if err != nil {
+				t.Fatalf("Error compiling pattern: %v", err)
+			}
+			if result := matchWithCompiled([]byte(tt.line), re); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func Test_match_fixed_strings(t *testing.T) {
+	tests := []struct {
+		line       string
+		patterns   []string
+		ignoreCase bool
+		expected   bool
+	}{
+		{"GET /a.b?x=1", []string{"a.b?"}, false, true},
+		{"GET /axb", []string{"a.b"}, false, false},
+		{"host 10.0.0.7 denied", []string{"10.0.0.1", "10.0.0.7"}, false, true},
+		{"host 10.0.0.8 denied", []string{"10.0.0.1", "10.0.0.7"}, false, false},
+		{"(unclosed", []string{"(unc"}, false, true},
+		{"DENIED", []string{"denied"}, true, true},
+		{"DENIED", []string{"denied"}, false, false},
+		{"anything", []string{""}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line+"_"+strings.Join(tt.patterns, "|"), func(t *testing.T) {
+			match, err := newMatcher(&options{fixedStrings: true, ignoreCase: tt.ignoreCase, patterns: tt.patterns})
+			if err != nil {
+				t.Fatalf("newMatcher() error = %v", err)
+			}
+			if result := match.Match([]byte(tt.line)); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func Test_parseArgs_dialect(t *testing.T) {
+	tests := []struct {
+		args         []string
+		wantExtended bool
+		wantPatterns []string
+		wantPaths    []string
+	}{
+		{args: []string{"-E", "a+", "f"}, wantExtended: true, wantPatterns: []string{"a+"}, wantPaths: []string{"f"}},
+		{args: []string{"-e", "a\\+", "f"}, wantExtended: false, wantPatterns: []string{"a\\+"}, wantPaths: []string{"f"}},
+		{args: []string{"a\\+", "f1", "f2"}, wantExtended: false, wantPatterns: []string{"a\\+"}, wantPaths: []string{"f1", "f2"}},
+		{args: []string{"-E", "a+", "-G"}, wantExtended: false, wantPatterns: []string{"a+"}, wantPaths: []string{}},
+		{args: []string{"-E", "-e", "a+", "f"}, wantExtended: true, wantPatterns: []string{"a+"}, wantPaths: []string{"f"}},
+		{args: []string{"-e", "a+", "-E", "f"}, wantExtended: true, wantPatterns: []string{"a+"}, wantPaths: []string{"f"}},
+		{args: []string{"a+", "-E", "f"}, wantExtended: true, wantPatterns: []string{"a+"}, wantPaths: []string{"f"}},
+		{args: []string{"--extended-regexp", "--regexp", "-x", "f"}, wantExtended: true, wantPatterns: []string{"-x"}, wantPaths: []string{"f"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			opts, err := parseArgs(tt.args)
+			if err != nil {
+				t.Fatalf("parseArgs() error = %v", err)
+			}
+			if opts.extended != tt.wantExtended {
+				t.Errorf("parseArgs() extended = %v, want %v", opts.extended, tt.wantExtended)
+			}
+			if !slices.Equal(opts.patterns, tt.wantPatterns) || !slices.Equal(opts.paths, tt.wantPaths) {
+				t.Errorf("parseArgs() patterns = %q, paths = %q", opts.patterns, opts.paths)
+			}
+		})
+	}
+}
+
+func Test_match_basic_regexp(t *testing.T) {
+	tests := []testcase{
+		{"f(x)", "f(x)", true},
+		{"fx", "f\\(x\\)", true},
+		{"abab", "^\\(ab\\)\\1$", true},
+		{"a+b", "a+b", true},
+		{"aab", "a\\+b", true},
+		{"aab", "a+b", false},
+		{"aaa", "^a\\{3\\}$", true},
+		{"a{3}", "a{3}", true},
+		{"cat", "dog\\|cat", true},
+		{"*star", "*star", true},
+		{"a^b", "a^b", true},
+		{"cost $5", "$5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line+"_"+tt.pattern, func(t *testing.T) {
+			re, err := compilePattern(tt.pattern, &options{})
+			if err != nil {
+				t.Fatalf("Error compiling pattern: %v", err)
+			}
+			if result := matchWithCompiled([]byte(tt.line), re); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func Test_processReader(t *testing.T) {
+	match, err := newMatcher(&options{extended: true, patterns: []string{"err(or)?"}})
+	if err != nil {
+		t.Fatalf("newMatcher() error = %v", err)
+	}
+	input := "ok\nerror: disk\nfine\nerr 2\n"
+
+	tests := []struct {
+		name         string
+		alwaysPrefix bool
+		want         string
+	}{
+		{name: "plain", want: "error: disk\nerr 2\n"},
+		{name: "prefixed", alwaysPrefix: true, want: "stdin:error: disk\nstdin:err 2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			s := &searcher{opts: &options{}, match: match, out: out}
+			found, err := s.processReader(strings.NewReader(input), "stdin", tt.alwaysPrefix)
+			if err != nil {
+				t.Fatalf("processReader() error = %v", err)
+			}
+			if !found {
+				t.Errorf("processReader() found = false")
+			}
+			if out.String() != tt.want {
+				t.Errorf("processReader() printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseArgs_label(t *testing.T) {
+	for _, args := range [][]string{{"--label", "app.log", "-e", "x", "-"}, {"--label=app.log", "-e", "x", "-"}} {
+		opts, err := parseArgs(args)
+		if err != nil {
+			t.Fatalf("parseArgs(%q) error = %v", args, err)
+		}
+		if opts.label != "app.log" || !slices.Equal(opts.paths, []string{"-"}) {
+			t.Errorf("parseArgs(%q) label = %q, paths = %q", args, opts.label, opts.paths)
+		}
+	}
+
+	opts, err := parseArgs([]string{"x"})
+	if err != nil {
+		t.Fatalf("parseArgs() error = %v", err)
+	}
+	if opts.label != "(standard input)" {
+		t.Errorf("parseArgs() default label = %q", opts.label)
+	}
+}
+
+func Test_match_line_and_word_regexp(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     options
+		line     string
+		expected bool
+	}{
+		{name: "-x whole line", opts: options{lineRegexp: true, patterns: []string{"ab|cd"}}, line: "cd", expected: true},
+		{name: "-x partial line", opts: options{lineRegexp: true, patterns: []string{"ab|cd"}}, line: "abcd", expected: false},
+		{name: "-x keeps groups", opts: options{lineRegexp: true, patterns: []string{"(a)b\\1"}}, line: "aba", expected: true},
+		{name: "-w whole word", opts: options{wordRegexp: true, patterns: []string{"cat"}}, line: "a cat.", expected: true},
+		{name: "-w inside word", opts: options{wordRegexp: true, patterns: []string{"cat"}}, line: "concatenate", expected: false},
+		{name: "-w later match", opts: options{wordRegexp: true, patterns: []string{"cat"}}, line: "cats and cat", expected: true},
+		{name: "-w shorter match", opts: options{wordRegexp: true, patterns: []string{"ab*"}}, line: "abbbc a", expected: true},
+		{name: "-w non-word match", opts: options{wordRegexp: true, patterns: []string{"-"}}, line: "a - b", expected: true},
+		{name: "-w non-word match next to word", opts: options{wordRegexp: true, patterns: []string{"-"}}, line: "a-b", expected: false},
+		{name: "-F -x", opts: options{fixedStrings: true, lineRegexp: true, patterns: []string{"a.b"}}, line: "a.b", expected: true},
+		{name: "-F -x partial", opts: options{fixedStrings: true, lineRegexp: true, patterns: []string{"a.b"}}, line: "a.bc", expected: false},
+		{name: "-F -w", opts: options{fixedStrings: true, wordRegexp: true, patterns: []string{"a-b", "a"}}, line: "a-bc", expected: true},
+		{name: "-F -w inside word", opts: options{fixedStrings: true, wordRegexp: true, patterns: []string{"10.0.0.1"}}, line: "10.0.0.12", expected: false},
+		{name: "-F -w -i", opts: options{fixedStrings: true, wordRegexp: true, ignoreCase: true, patterns: []string{"error"}}, line: "an ERROR here", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.extended = true
+			match, err := newMatcher(&tt.opts)
+			if err != nil {
+				t.Fatalf("newMatcher() error = %v", err)
+			}
+			if result := match.Match([]byte(tt.line)); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func Test_newMatcher_wordRegexpUsesDFA(t *testing.T) {
+	match, err := newMatcher(&options{wordRegexp: true, extended: true, patterns: []string{"dis[kx] full"}})
+	if err != nil {
+		t.Fatalf("newMatcher() error = %v", err)
+	}
+	if m, ok := match.(*regexMatcher); !ok || m.dfa == nil {
+		t.Errorf("newMatcher() = %#v, want a regex matcher with a DFA", match)
+	}
+}
+
+func Test_processReader_invert(t *testing.T) {
+	got, found := runGrep(t, "err 1\nerror 2\nok\n", "-v", "-w", "err")
+	if !found || got != "error 2\nok\n" {
+		t.Errorf("processReader() = %v, printed %q", found, got)
+	}
+
+	got, found = runGrep(t, "err\n", "-v", "-w", "err")
+	if found || got != "" {
+		t.Errorf("processReader() with every line matching = %v, printed %q", found, got)
+	}
+}
+
+func Test_processReader_onlyMatching(t *testing.T) {
+	input := "id=17 id=42\nnone\nuser=ann id=7\n"
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "each match", args: []string{"id=[0-9]+"}, want: "id=17\nid=42\nid=7\n"},
+		{name: "group number", args: []string{"--only-group=1", "id=([0-9]+)"}, want: "17\n42\n7\n"},
+		{name: "group name", args: []string{"--only-group", "value", "(?<key>[a-z]+)=(?<value>[0-9]+)"}, want: "17\n42\n7\n"},
+		{name: "group not taking part", args: []string{"--only-group=1", "user=(x)?|id=[0-9]+"}, want: ""},
+		{name: "empty matches skipped", args: []string{"[0-9]*"}, want: "17\n42\n7\n"},
+		{name: "fixed strings", args: []string{"-F", "-e", "id=", "-e", "id=4"}, want: "id=\nid=4\nid=\n"},
+		{name: "fixed whole words", args: []string{"-F", "-w", "-e", "id", "-e", "7"}, want: "id\nid\nid\n7\n"},
+		{name: "invert prints nothing", args: []string{"-v", "id"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := runGrep(t, input, append([]string{"-o", "-E"}, tt.args...)...); got != tt.want {
+				t.Errorf("processReader() printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("prefixed", func(t *testing.T) {
+		s, out := newTestSearcher(t, "-o", "-E", "id=[0-9]+")
+		if _, err := s.processReader(strings.NewReader(input), "log", true); err != nil {
+			t.Fatalf("processReader() error = %v", err)
+		}
+		if want := "log:id=17\nlog:id=42\nlog:id=7\n"; out.String() != want {
+			t.Errorf("processReader() printed %q, want %q", out.String(), want)
+		}
+	})
+}
+
+func Test_groupIndex(t *testing.T) {
+	names := []string{"", "key", ""}
+	tests := []struct {
+		group   string
+		want    int
+		wantErr bool
+	}{
+		{group: "0", want: 0},
+		{group: "2", want: 2},
+		{group: "key", want: 1},
+		{group: "3", wantErr: true},
+		{group: "-1", wantErr: true},
+		{group: "value", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.group, func(t *testing.T) {
+			got, err := groupIndex(names, tt.group)
+			if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+				t.Errorf("groupIndex(%q) = %d, %v", tt.group, got, err)
+			}
+		})
+	}
+}
+
+func Test_parseArgs_onlyMatching(t *testing.T) {
+	for _, args := range [][]string{{"--only-group", "id", "x"}, {"--only-group=id", "x"}} {
+		opts, err := parseArgs(args)
+		if err != nil {
+			t.Fatalf("parseArgs(%q) error = %v", args, err)
+		}
+		if !opts.onlyMatching || opts.onlyGroup != "id" || !slices.Equal(opts.patterns, []string{"x"}) {
+			t.Errorf("parseArgs(%q) onlyMatching = %v, onlyGroup = %q", args, opts.onlyMatching, opts.onlyGroup)
+		}
+	}
+}
+
+// newTestSearcher sets up a searcher for args, given as on the command line, that writes
+// to the returned buffer.
+func newTestSearcher(t *testing.T, args ...string) (*searcher, *bytes.Buffer) {
+	t.Helper()
+	opts, err := parseArgs(args)
+	if err != nil {
+		t.Fatalf("parseArgs(%q) error = %v", args, err)
+	}
+	match, err := newMatcher(opts)
+	if err != nil {
+		t.Fatalf("newMatcher() error = %v", err)
+	}
+	out := &bytes.Buffer{}
+	s := &searcher{opts: opts, match: match, out: out}
+	if opts.onlyGroup != "" {
+		if s.group, err = groupIndex(match.SubexpNames(), opts.onlyGroup); err != nil {
+			t.Fatalf("groupIndex() error = %v", err)
+		}
+	}
+	return s, out
+}
+
+// runGrep searches input as standard input with args given as on the command line, and
+// returns what was printed and whether the search succeeded.
+func runGrep(t *testing.T, input string, args ...string) (out string, matched bool) {
+	t.Helper()
+	s, buf := newTestSearcher(t, args...)
+	matched, err := s.processReader(strings.NewReader(input), s.opts.label, false)
+	if err != nil {
+		t.Fatalf("processReader() error = %v", err)
+	}
+	return buf.String(), matched
+}
+
+func Test_processReader_positions(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		want  string
+	}{
+		{name: "line numbers", args: []string{"-n", "foo"}, want: "2:foo bar foo\n"},
+		{name: "byte offsets", args: []string{"-b", "bar"}, want: "6:foo bar foo\n18:bar\n"},
+		{name: "byte offsets of matches", args: []string{"-b", "-o", "foo"}, want: "6:foo\n14:foo\n"},
+		{name: "column", args: []string{"--column", "bar"}, want: "2:5:foo bar foo\n3:1:bar\n"},
+		{name: "column of each match", args: []string{"--column", "-o", "foo"}, want: "2:1:foo\n2:9:foo\n"},
+		{name: "column with invert", args: []string{"--column", "-v", "foo"}, want: "1:skip\n3:bar\n"},
+		{name: "all prefixes", args: []string{"-n", "-b", "--column", "bar$"}, want: "3:1:18:bar\n"},
+		{name: "vimgrep", args: []string{"--vimgrep", "--label=log", "foo"}, want: "log:2:1:foo bar foo\nlog:2:9:foo bar foo\n"},
+		{name: "vimgrep names stdin", args: []string{"--vimgrep", "b"}, input: "abc", want: "(standard input):1:2:abc\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := cmp.Or(tt.input, "skip\r\nfoo bar foo\nbar\n")
+			if got, _ := runGrep(t, input, tt.args...); got != tt.want {
+				t.Errorf("processReader() printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_processReader_context(t *testing.T) {
+	input := "a\nerr1\nb\nc\nd\ne\nerr2\nerr3\nf\ng\n"
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "after", args: []string{"-A1", "err"}, want: "err1\nb\n--\nerr2\nerr3\nf\n"},
+		{name: "before", args: []string{"-B", "2", "err"}, want: "a\nerr1\n--\nd\ne\nerr2\nerr3\n"},
+		{name: "groups merge", args: []string{"-C", "2", "err"}, want: "a\nerr1\nb\nc\nd\ne\nerr2\nerr3\nf\ng\n"},
+		{name: "line numbers", args: []string{"-n", "--context=1", "err"}, want: "1-a\n2:err1\n3-b\n--\n6-e\n7:err2\n8:err3\n9-f\n"},
+		{name: "invert", args: []string{"-v", "-n", "-A1", "^[a-f]$"}, want: "2:err1\n3-b\n--\n7:err2\n8:err3\n9-f\n10:g\n"},
+		{name: "after overrides context", args: []string{"-C1", "-A0", "err3"}, want: "err2\nerr3\n"},
+		{name: "no context with -o", args: []string{"-o", "-C1", "err[0-9]"}, want: "err1\nerr2\nerr3\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := runGrep(t, input, tt.args...); got != tt.want {
+				t.Errorf("processReader() printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_processReader_contextAcrossFiles(t *testing.T) {
+	s, out := newTestSearcher(t, "-B1", "err")
+	for _, name := range []string{"a.log", "b.log"} {
+		if _, err := s.processReader(strings.NewReader("err\nok\n"), name, true); err != nil {
+			t.Fatalf("processReader() error = %v", err)
+		}
+	}
+	if want := "a.log:err\n--\nb.log:err\n"; out.String() != want {
+		t.Errorf("processReader() printed %q, want %q", out.String(), want)
+	}
+}
+
+func Test_parseArgs_context(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantAfter  int
+		wantBefore int
+		wantErr    bool
+	}{
+		{args: []string{"-A", "2", "x"}, wantAfter: 2},
+		{args: []string{"-B3", "x"}, wantBefore: 3},
+		{args: []string{"--before-context=1", "-C", "4", "x"}, wantAfter: 4, wantBefore: 4},
+		{args: []string{"-C4", "--after-context", "0", "x"}, wantAfter: 0, wantBefore: 4},
+		{args: []string{"-A", "x", "x"}, wantErr: true},
+		{args: []string{"-C-1", "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			opts, err := parseArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (opts.after != tt.wantAfter || opts.before != tt.wantBefore) {
+				t.Errorf("parseArgs() after = %d, before = %d", opts.after, opts.before)
+			}
+		})
+	}
+}
+
+func Test_contextRing(t *testing.T) {
+	r := newContextRing(2)
+	for i, text := range []string{"a", "b", "c"} {
+		r.push(i+1, i, []byte(text))
+	}
+
+	var got []string
+	r.drain(func(l *contextLine) {
+		got = append(got, fmt.Sprintf("%d:%s", l.lineNo, l.text))
+	})
+	if want := []string{"2:b", "3:c"}; !slices.Equal(got, want) {
+		t.Errorf("drain() = %v, want %v", got, want)
+	}
+
+	r.drain(func(l *contextLine) {
+		t.Errorf("drain() after draining got line %d", l.lineNo)
+	})
+}
+
+func Test_processReader_summaries(t *testing.T) {
+	input := "err1\nok\nerr2\nerr3\nok\n"
+
+	tests := []struct {
+		name      string
+		args      []string
+		want      string
+		wantFound bool
+	}{
+		{name: "count", args: []string{"-c", "err"}, want: "3\n", wantFound: true},
+		{name: "count inverted", args: []string{"-c", "-v", "err"}, want: "2\n", wantFound: true},
+		{name: "count none", args: []string{"-c", "nope"}, want: "0\n"},
+		{name: "count up to max", args: []string{"-c", "-m", "2", "err"}, want: "2\n", wantFound: true},
+		{name: "files with matches", args: []string{"-l", "err"}, want: "(standard input)\n", wantFound: true},
+		{name: "files with matches none", args: []string{"-l", "nope"}, want: ""},
+		{name: "files without match", args: []string{"-L", "--label=log", "nope"}, want: "log\n", wantFound: true},
+		{name: "files without match none", args: []string{"-L", "err"}, want: ""},
+		{name: "files without match quiet", args: []string{"-q", "-L", "err"}, want: "", wantFound: true},
+		{name: "quiet", args: []string{"-q", "-n", "err"}, want: "", wantFound: true},
+		{name: "max count", args: []string{"-n", "-m1", "err"}, want: "1:err1\n", wantFound: true},
+		{name: "max count trailing context", args: []string{"-n", "-m2", "-A2", "err"}, want: "1:err1\n2-ok\n3:err2\n4-err3\n5-ok\n", wantFound: true},
+		{name: "max count zero", args: []string{"-c", "--max-count=0", "err"}, want: "0\n"},
+		{name: "max count zero files with matches", args: []string{"-l", "-m0", "err"}, want: ""},
+		{name: "max count zero files without match", args: []string{"-L", "-m0", "err"}, want: "(standard input)\n", wantFound: true},
+		{name: "max count zero lines", args: []string{"-n", "-m0", "-A1", "err"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := runGrep(t, input, tt.args...)
+			if found != tt.wantFound || got != tt.want {
+				t.Errorf("processReader() = %v, printed %q, want %v, %q", found, got, tt.wantFound, tt.want)
+			}
+		})
+	}
+}
+
+// failingReader fails every read, standing in for the rest of a huge file.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read past the first match")
+}
+
+func Test_processReader_stopsEarly(t *testing.T) {
+	for _, args := range [][]string{{"-l", "err"}, {"-q", "err"}, {"-m1", "err"}} {
+		s, _ := newTestSearcher(t, args...)
+		r := io.MultiReader(strings.NewReader("ok\nerr\n"), failingReader{})
+		if found, err := s.processReader(r, "log", false); !found || err != nil {
+			t.Errorf("processReader(%q) = %v, %v", args, found, err)
+		}
+	}
+}
+
+func Test_outputPalette(t *testing.T) {
+	env := func(vars ...string) func(string) string {
+		return func(key string) string {
+			for i := 0; i+1 < len(vars); i += 2 {
+				if vars[i] == key {
+					return vars[i+1]
+				}
+			}
+			return ""
+		}
+	}
+
+	tests := []struct {
+		name     string
+		when     string
+		terminal bool
+		getenv   func(string) string
+		want     palette
+	}{
+		{name: "auto on a terminal", when: "auto", terminal: true, getenv: env(), want: defaultPalette},
+		{name: "auto to a pipe", when: "auto", terminal: false, getenv: env(), want: palette{}},
+		{name: "auto with NO_COLOR", when: "auto", terminal: true, getenv: env("NO_COLOR", "1"), want: palette{}},
+		{name: "auto on a dumb terminal", when: "auto", terminal: true, getenv: env("TERM", "dumb"), want: palette{}},
+		{name: "always to a pipe", when: "always", terminal: false, getenv: env("NO_COLOR", "1"), want: defaultPalette},
+		{name: "never", when: "never", terminal: true, getenv: env(), want: palette{}},
+		{
+			name: "GREP_COLORS", when: "always", getenv: env("GREP_COLORS", "mt=01;32:mc=35:fn=:ne:xx=1"),
+			want: palette{selectedMatch: "01;32", contextMatch: "35", lineNumber: "32", byteOffset: "32", separator: "36"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outputPalette(tt.when, tt.terminal, tt.getenv); got != tt.want {
+				t.Errorf("outputPalette() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isTerminal_devNull(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A character device, but not a terminal
+	if isTerminal(f) {
+		t.Errorf("isTerminal(%s) = true, want false", os.DevNull)
+	}
+}
+
+func Test_processReader_color(t *testing.T) {
+	colors := palette{selectedMatch: "1", contextMatch: "2", fileName: "3", lineNumber: "4", separator: "5"}
+	color := func(sgr, text string) string {
+		return "\x1b[" + sgr + "m\x1b[K" + text + "\x1b[m\x1b[K"
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		alwaysPrefix bool
+		want         string
+	}{
+		{name: "every match", args: []string{"ab*"}, want: "x" + color("1", "abb") + "y" + color("1", "a") + "\n"},
+		{name: "empty matches", args: []string{"b*"}, want: "xa" + color("1", "bb") + "ya\n"},
+		{
+			name: "prefixes", args: []string{"-n", "y"}, alwaysPrefix: true,
+			want: color("3", "log") + color("5", ":") + color("4", "1") + color("5", ":") + "xabb" + color("1", "y") + "a\n",
+		},
+		{
+			name: "context", args: []string{"-v", "-B1", "-n", "a"},
+			want: color("4", "1") + color("5", "-") + "x" + color("2", "a") + "bby" + color("2", "a") + "\n" +
+				color("4", "2") + color("5", ":") + "zz\n",
+		},
+		{name: "only matching", args: []string{"-o", "b+"}, want: color("1", "bb") + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out := newTestSearcher(t, append([]string{"-E"}, tt.args...)...)
+			s.colors = colors
+			input := "xabbya\n"
+			if s.opts.invert {
+				input += "zz\n"
+			}
+			if _, err := s.processReader(strings.NewReader(input), "log", tt.alwaysPrefix); err != nil {
+				t.Fatalf("processReader() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("processReader() printed %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseArgs_color(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"x"}, want: "auto"},
+		{args: []string{"--color=always", "x"}, want: "always"},
+		{args: []string{"--colour=never", "--color", "x"}, want: "auto"},
+		{args: []string{"--color=sometimes", "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			opts, err := parseArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && opts.color != tt.want {
+				t.Errorf("parseArgs() color = %q, want %q", opts.color, tt.want)
+			}
+		})
+	}
+}
+
+func Test_processReader_json(t *testing.T) {
+	s, out := newTestSearcher(t, "--json", "-B1", "-E", "(?<key>[a-z]+)=([0-9]+)")
+	for _, name := range []string{"a.log", "b:\xff.log"} {
+		if _, err := s.processReader(strings.NewReader("start\r\nid=7 n=10\n\xff\n"), name, true); err != nil {
+			t.Fatalf("processReader() error = %v", err)
+		}
+	}
+	if _, err := s.processReader(strings.NewReader("none\n"), "c.log", true); err != nil {
+		t.Fatalf("processReader() error = %v", err)
+	}
+	s.writeJSONSummary()
+
+	var messages []map[string]any
+	for line := range strings.Lines(out.String()) {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		messages = append(messages, m)
+	}
+
+	var types []string
+	for _, m := range messages {
+		types = append(types, m["type"].(string))
+	}
+	want := []string{"begin", "context", "match", "end", "begin", "context", "match", "end", "summary"}
+	if !slices.Equal(types, want) {
+		t.Fatalf("message types = %v, want %v", types, want)
+	}
+
+	check := func(msg int, key string, want string) {
+		t.Helper()
+		got, err := json.Marshal(messages[msg]["data"].(map[string]any)[key])
+		if err != nil {
+			t.Fatalf("marshal %s: %v", key, err)
+		}
+		if string(got) != want {
+			t.Errorf("message %d %s = %s, want %s", msg, key, got, want)
+		}
+	}
+	check(1, "lines", `{"text":"start\r\n"}`)
+	check(1, "submatches", `[]`)
+	check(2, "line_number", `2`)
+	check(2, "absolute_offset", `7`)
+	check(2, "submatches", `[{"end":4,"groups":[{"end":2,"group":1,"match":{"text":"id"},"name":"key","start":0},{"end":4,"group":2,"match":{"text":"7"},"start":3}],"match":{"text":"id=7"},"start":0},`+
+		`{"end":9,"groups":[{"end":6,"group":1,"match":{"text":"n"},"name":"key","start":5},{"end":9,"group":2,"match":{"text":"10"},"start":7}],"match":{"text":"n=10"},"start":5}]`)
+	check(4, "path", `{"bytes":"Yjr/LmxvZw=="}`)
+
+	stats := messages[8]["data"].(map[string]any)["stats"].(map[string]any)
+	for key, want := range map[string]float64{"searches": 3, "searches_with_match": 2, "bytes_searched": 43, "matched_lines": 2, "matches": 4} {
+		if stats[key] != want {
+			t.Errorf("summary %s = %v, want %v", key, stats[key], want)
+		}
+	}
+}
+
+func Test_processReader_jsonInvertContext(t *testing.T) {
+	out, _ := runGrep(t, "xa\nb\naab\n", "--json", "-v", "-C1", "-E", "a+")
+
+	// Under -v the context lines are the ones holding matches
+	var got []string
+	for line := range strings.Lines(out) {
+		var m struct {
+			Type string
+			Data struct{ Submatches json.RawMessage }
+		}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if m.Type == "context" || m.Type == "match" {
+			got = append(got, m.Type+" "+string(m.Data.Submatches))
+		}
+	}
+	want := []string{
+		`context [{"match":{"text":"a"},"start":1,"end":2}]`,
+		`match []`,
+		`context [{"match":{"text":"aa"},"start":0,"end":2}]`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func Test_searchParallel(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.log":       "err 1\nok\n",
+		"b/c.log":     "ok\nerr 2\n",
+		"b/d/e.log":   "nothing\n",
+		"b/d/f.log":   "err 3\nerr 4\n",
+		"g.log":       "ok\n",
+		"h/i/j/k.log": "err 5\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rel := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	search := func(t *testing.T, args ...string) (string, bool, error) {
+		t.Helper()
+		s, out := newTestSearcher(t, append(args, "err", dir)...)
+		var errs []error
+		found := s.searchParallel(s.opts.paths, func(err error) { errs = append(errs, err) })
+		return out.String(), found, errors.Join(errs...)
+	}
+
+	t.Run("sorted", func(t *testing.T) {
+		want := rel("a.log") + ":err 1\n" + rel("b/c.log") + ":err 2\n" + rel("b/d/f.log") + ":err 3\n" +
+			rel("b/d/f.log") + ":err 4\n" + rel("h/i/j/k.log") + ":err 5\n"
+		for range 20 {
+			got, found, err := search(t, "-j", "4", "--sort", "path")
+			if err != nil || !found || got != want {
+				t.Fatalf("searchParallel() = %v, %v, printed %q, want %q", found, err, got, want)
+			}
+		}
+	})
+
+	t.Run("completion order keeps each file together", func(t *testing.T) {
+		got, _, err := search(t, "-j8", "-c")
+		if err != nil {
+			t.Fatalf("searchParallel() error = %v", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		slices.Sort(lines)
+		want := []string{rel("a.log") + ":1", rel("b/c.log") + ":1", rel("b/d/e.log") + ":0", rel("b/d/f.log") + ":2", rel("g.log") + ":0", rel("h/i/j/k.log") + ":1"}
+		if !slices.Equal(lines, want) {
+			t.Errorf("searchParallel() printed %q, want %q", lines, want)
+		}
+	})
+
+	t.Run("context separators between files", func(t *testing.T) {
+		got, _, err := search(t, "--sort=path", "-A1", "-n")
+		if err != nil {
+			t.Fatalf("searchParallel() error = %v", err)
+		}
+		want := rel("a.log") + ":1:err 1\n" + rel("a.log") + "-2-ok\n--\n" + rel("b/c.log") + ":2:err 2\n--\n" +
+			rel("b/d/f.log") + ":1:err 3\n" + rel("b/d/f.log") + ":2:err 4\n--\n" + rel("h/i/j/k.log") + ":1:err 5\n"
+		if got != want {
+			t.Errorf("searchParallel() printed %q, want %q", got, want)
+		}
+	})
+
+	t.Run("quiet", func(t *testing.T) {
+		got, found, err := search(t, "-q", "-j", "2")
+		if err != nil || !found || got != "" {
+			t.Errorf("searchParallel() = %v, %v, printed %q", found, err, got)
+		}
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		s, out := newTestSearcher(t, "-r", "err", rel("missing"), rel("a.log"))
+		var errs []error
+		found := s.searchParallel(s.opts.paths, func(err error) { errs = append(errs, err) })
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "stat path") {
+			t.Errorf("searchParallel() errors = %v, want a stat error", errs)
+		}
+		if want := rel("a.log") + ":err 1\n"; !found || out.String() != want {
+			t.Errorf("searchParallel() = %v, printed %q, want the rest searched", found, out.String())
+		}
+	})
+
+	t.Run("quiet match after missing path", func(t *testing.T) {
+		s, _ := newTestSearcher(t, "-q", "-r", "err", rel("missing"), rel("a.log"))
+		if !s.searchParallel(s.opts.paths, func(error) {}) {
+			t.Errorf("searchParallel() = false, want a match after the error")
+		}
+	})
+}
+
+func Test_parseArgs_parallel(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantJobs int
+		wantSort bool
+		wantErr  bool
+	}{
+		{args: []string{"x"}},
+		{args: []string{"-j", "4", "--sort", "path", "x"}, wantJobs: 4, wantSort: true},
+		{args: []string{"-j2", "--sort=path", "--sort=none", "x"}, wantJobs: 2},
+		{args: []string{"--threads=3", "x"}, wantJobs: 3},
+		{args: []string{"--sort", "size", "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			opts, err := parseArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (opts.jobs != tt.wantJobs || opts.sortPath != tt.wantSort) {
+				t.Errorf("parseArgs() jobs = %d, sortPath = %v", opts.jobs, opts.sortPath)
+			}
+		})
+	}
+}
+
+func Test_processReader_utf8MatchStarts(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "negated class", args: []string{"-c", "-u", "-E", "[^é]"}, want: "0\n"},
+		{name: "negated property", args: []string{"-o", "-u", "-E", "\\P{L}"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := runGrep(t, "é\n", tt.args...); got != tt.want {
+				t.Errorf("processReader() printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
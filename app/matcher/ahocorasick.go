@@ -0,0 +1,147 @@
+package matcher
+
+// AhoCorasick finds any of a set of literal strings in a single pass over the input,
+// however many there are. It is used for fixed-string searches, where the patterns are
+// taken as they are instead of being parsed as regexes.
+//
+// An AhoCorasick is read-only once built and safe for concurrent use.
+type AhoCorasick struct {
+	nodes    []acNode
+	lens     []int // Length of each pattern
+	maxLen   int
+	foldCase bool
+}
+
+// acNode is a node of the trie of patterns. Its path from the root spells a prefix of
+// one or more patterns.
+type acNode struct {
+	next map[byte]int32
+	fail int32 // Node for the longest proper suffix of this node that is also in the trie
+	out  []int // Patterns ending here, including those reached through fail links
+}
+
+// NewAhoCorasick builds an automaton matching any of patterns. With foldCase, ASCII
+// letters match either case.
+func NewAhoCorasick(patterns [][]byte, foldCase bool) *AhoCorasick {
+	ac := &AhoCorasick{
+		nodes:    []acNode{{next: map[byte]int32{}}},
+		lens:     make([]int, len(patterns)),
+		foldCase: foldCase,
+	}
+
+	for i, p := range patterns {
+		cur := int32(0)
+		for _, c := range p {
+			c = ac.fold(c)
+			child, ok := ac.nodes[cur].next[c]
+			if !ok {
+				child = int32(len(ac.nodes))
+				ac.nodes = append(ac.nodes, acNode{next: map[byte]int32{}})
+				ac.nodes[cur].next[c] = child
+			}
+			cur = child
+		}
+		ac.nodes[cur].out = append(ac.nodes[cur].out, i)
+		ac.lens[i] = len(p)
+		ac.maxLen = max(ac.maxLen, len(p))
+	}
+
+	// Breadth-first, so the fail node of every node is finished before its children
+	queue := []int32{}
+	for _, child := range ac.nodes[0].next {
+		ac.nodes[child].out = append(ac.nodes[child].out, ac.nodes[0].out...)
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for c, child := range ac.nodes[n].next {
+			fail := ac.nodes[n].fail
+			for fail != 0 {
+				if _, ok := ac.nodes[fail].next[c]; ok {
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			if target, ok := ac.nodes[fail].next[c]; ok && target != child {
+				fail = target
+			}
+			ac.nodes[child].fail = fail
+			ac.nodes[child].out = append(ac.nodes[child].out, ac.nodes[fail].out...)
+			queue = append(queue, child)
+		}
+	}
+
+	return ac
+}
+
+// Match reports whether any pattern occurs in input.
+func (ac *AhoCorasick) Match(input []byte) bool {
+	_, _, ok := ac.Index(input)
+	return ok
+}
+
+// Index returns the span of the leftmost occurrence of any pattern in input, preferring
+// the longest pattern among those starting at the same position.
+func (ac *AhoCorasick) Index(input []byte) (start, end int, ok bool) {
+	return ac.IndexFunc(input, 0, nil)
+}
+
+// IndexFunc is like Index but only considers occurrences starting at or after from for
+// which accept, if not nil, returns true. Spans are relative to input, so accept can look
+// at the text around an occurrence.
+func (ac *AhoCorasick) IndexFunc(input []byte, from int, accept func(start, end int) bool) (start, end int, ok bool) {
+	start, end = -1, -1
+	ac.Each(input[from:], func(s, e int) bool {
+		s, e = s+from, e+from
+		// No later occurrence can start before the one found
+		if start != -1 && e > start+ac.maxLen {
+			return false
+		}
+		if accept != nil && !accept(s, e) {
+			return true
+		}
+		if start == -1 || s < start || (s == start && e > end) {
+			start, end = s, e
+		}
+		return true
+	})
+
+	return start, end, start != -1
+}
+
+// Each calls fn with the span of every occurrence of a pattern in input, in order of their
+// end and longest first for the same end, until fn returns false.
+func (ac *AhoCorasick) Each(input []byte, fn func(start, end int) bool) {
+	if len(ac.nodes[0].out) > 0 && !fn(0, 0) {
+		return
+	}
+
+	cur := int32(0)
+	for i, c := range input {
+		c = ac.fold(c)
+		for {
+			if child, ok := ac.nodes[cur].next[c]; ok {
+				cur = child
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = ac.nodes[cur].fail
+		}
+
+		for _, p := range ac.nodes[cur].out {
+			if !fn(i+1-ac.lens[p], i+1) {
+				return
+			}
+		}
+	}
+}
+
+func (ac *AhoCorasick) fold(c byte) byte {
+	if ac.foldCase && c >= 'A' && c <= 'Z' {
+		return c - 'A' + 'a'
+	}
+	return c
+}
@@ -0,0 +1,116 @@
+package matcher
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick_Index(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		foldCase bool
+		input    string
+		want     string // Matched text, or "-" for no match
+	}{
+		{name: "single", patterns: []string{"abc"}, input: "xxabcxx", want: "abc"},
+		{name: "no match", patterns: []string{"abc", "bcd"}, input: "abxbc", want: "-"},
+		{name: "leftmost wins", patterns: []string{"cd", "abcde"}, input: "xabcdex", want: "abcde"},
+		{name: "longest at same start", patterns: []string{"he", "hers", "her"}, input: "ushers", want: "hers"},
+		{name: "match found through fail link", patterns: []string{"she", "he"}, input: "ahex", want: "he"},
+		{name: "metacharacters are literal", patterns: []string{"a.b", "(x)"}, input: "axb (x)", want: "(x)"},
+		{name: "fold case", patterns: []string{"Error"}, foldCase: true, input: "disk ERROR", want: "ERROR"},
+		{name: "case sensitive", patterns: []string{"Error"}, input: "disk ERROR", want: "-"},
+		{name: "empty pattern", patterns: []string{""}, input: "abc", want: ""},
+		{name: "overlapping prefixes", patterns: []string{"aab", "ab"}, input: "aaab", want: "aab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := make([][]byte, len(tt.patterns))
+			for i, p := range tt.patterns {
+				patterns[i] = []byte(p)
+			}
+			ac := NewAhoCorasick(patterns, tt.foldCase)
+
+			got := "-"
+			if start, end, ok := ac.Index([]byte(tt.input)); ok {
+				got = tt.input[start:end]
+			}
+			if got != tt.want {
+				t.Errorf("Index(%q) matched %q, want %q", tt.input, got, tt.want)
+			}
+			if ac.Match([]byte(tt.input)) != (tt.want != "-") {
+				t.Errorf("Match(%q) = %v, want %v", tt.input, tt.want == "-", tt.want != "-")
+			}
+		})
+	}
+}
+
+func TestAhoCorasick_Each(t *testing.T) {
+	ac := NewAhoCorasick([][]byte{[]byte("he"), []byte("she"), []byte("hers")}, false)
+
+	var got []string
+	ac.Each([]byte("ushers"), func(start, end int) bool {
+		got = append(got, fmt.Sprintf("%d-%d", start, end))
+		return true
+	})
+	if want := []string{"1-4", "2-4", "2-6"}; !slices.Equal(got, want) {
+		t.Errorf("Each() spans = %v, want %v", got, want)
+	}
+
+	calls := 0
+	ac.Each([]byte("ushers"), func(start, end int) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Each() kept going after fn returned false: %d calls", calls)
+	}
+}
+
+func TestAhoCorasick_IndexFunc(t *testing.T) {
+	ac := NewAhoCorasick([][]byte{[]byte("ab"), []byte("abc"), []byte("c")}, false)
+	input := []byte("xabc abc")
+
+	tests := []struct {
+		name   string
+		from   int
+		accept func(start, end int) bool
+		want   string
+	}{
+		{name: "from start", from: 0, want: "1-4"},
+		{name: "from inside a match", from: 2, want: "3-4"},
+		{name: "from end", from: len(input), want: "-"},
+		{name: "rejects longest", from: 0, accept: func(start, end int) bool { return end-start == 2 }, want: "1-3"},
+		{name: "rejects leftmost", from: 0, accept: func(start, end int) bool { return start > 1 }, want: "3-4"},
+		{name: "rejects all", from: 0, accept: func(start, end int) bool { return false }, want: "-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := "-"
+			if start, end, ok := ac.IndexFunc(input, tt.from, tt.accept); ok {
+				got = fmt.Sprintf("%d-%d", start, end)
+			}
+			if got != tt.want {
+				t.Errorf("IndexFunc(%d) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkAhoCorasick_ManyPatterns(b *testing.B) {
+	var patterns [][]byte
+	for i := range 5000 {
+		patterns = append(patterns, fmt.Appendf(nil, "10.%d.%d.%d", i/65536, i/256%256, i%256))
+	}
+	ac := NewAhoCorasick(patterns, false)
+	input := []byte(strings.Repeat("GET /index.html 200 from 192.168.1.20 ", 10))
+
+	for b.Loop() {
+		ac.Match(input)
+	}
+}
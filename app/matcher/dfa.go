@@ -0,0 +1,235 @@
+package matcher
+
+import (
+	"errors"
+	"slices"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/codecrafters-io/grep-starter-go/app/parser"
+	"github.com/codecrafters-io/grep-starter-go/app/regex"
+)
+
+// ErrDFAUnsupported is returned by NewDFA for patterns the DFA cannot run, such as those
+// using backreferences, lookarounds or UTF-8 aware classes.
+var ErrDFAUnsupported = errors.New("pattern not supported by the DFA")
+
+// DefaultDFAMemoryBudget is the default size of the DFA state cache in bytes.
+const DefaultDFAMemoryBudget = 4 << 20
+
+// endOfInput is the input symbol used to look past the last byte.
+const endOfInput = 256
+
+// DFA answers whether a regex matches a line with a deterministic automaton built lazily
+// from the NFA. Each DFA state is the set of NFA states a search can be in, created the
+// first time a search reaches it and cached for the following bytes and lines. When the
+// cache outgrows its memory budget it is dropped and rebuilt on demand.
+//
+// Captures are not tracked, so it only reports whether there is a match. A DFA is not
+// safe for concurrent use.
+type DFA struct {
+	prog   *regex.Program
+	end    int
+	budget int
+
+	states  map[string]*dfaState
+	start   *dfaState
+	matched *dfaState // Sentinel target of transitions that complete a match
+	memory  int       // Approximate size of the cached states
+
+	// Scratch space reused while building states
+	key   []byte
+	stack []int
+	seen  []int // Per NFA state, the generation it was last added to a closure in
+	gen   int
+}
+
+// dfaState is a set of NFA states reached after consuming some input, before following
+// their epsilon transitions. Zero-width assertions are resolved when the next byte is
+// known, so the state also records what they need to know about the input behind it.
+type dfaState struct {
+	kernel   []int
+	atStart  bool // No input consumed yet, for ^
+	prevWord bool // The last byte was a word character, for \b and \B
+	// Transitions by next byte, with endOfInput for the end. nil if not built yet.
+	next [endOfInput + 1]*dfaState
+}
+
+// NewDFA prepares a lazy DFA for re, or returns ErrDFAUnsupported.
+func NewDFA(re *regex.CompiledRegex) (*DFA, error) {
+	prog := re.Program()
+	for _, s := range prog.States {
+		for _, tr := range s.Transitions {
+			switch tr.Transitioner.(type) {
+			case regex.CharTransitioner, regex.EpsilonTransitioner, regex.StartOfStringTransitioner,
+				regex.EndOfStringTransitioner, regex.WordBoundaryTransitioner, regex.NoWordTransitioner:
+			default:
+				return nil, ErrDFAUnsupported
+			}
+		}
+	}
+
+	d := &DFA{
+		prog:    prog,
+		end:     prog.Index[re.EndingState()],
+		budget:  DefaultDFAMemoryBudget,
+		matched: &dfaState{},
+		seen:    make([]int, len(prog.States)),
+	}
+	d.reset()
+
+	return d, nil
+}
+
+// WithMemoryBudget sets the approximate number of bytes the state cache may use.
+func (d *DFA) WithMemoryBudget(bytes int) *DFA {
+	d.budget = bytes
+	d.reset()
+
+	return d
+}
+
+// Match reports whether the regex matches anywhere in input.
+func (d *DFA) Match(input []byte) bool {
+	start := firstCandidate(d.prog, input)
+	if start == -1 {
+		return false
+	}
+
+	s := d.startAt(input, start)
+	for _, c := range input[start:] {
+		next := s.next[c]
+		if next == nil {
+			next = d.step(s, int(c))
+		}
+		if next == d.matched {
+			return true
+		}
+		s = next
+	}
+
+	next := s.next[endOfInput]
+	if next == nil {
+		next = d.step(s, endOfInput)
+	}
+
+	return next == d.matched
+}
+
+// startAt returns the state for a search starting at pos.
+func (d *DFA) startAt(input []byte, pos int) *dfaState {
+	if pos == 0 {
+		if d.start == nil {
+			d.start = d.intern([]int{0}, true, false)
+		}
+		return d.start
+	}
+
+	return d.intern([]int{0}, false, parser.WordMatcher.Match(input[pos-1]))
+}
+
+// step builds and caches the transition of s on symbol c.
+func (d *DFA) step(s *dfaState, c int) *dfaState {
+	nextWord := c != endOfInput && parser.WordMatcher.Match(byte(c))
+
+	// Follow epsilon transitions whose assertions hold between the previous and next byte,
+	// collecting the targets of transitions that consume c.
+	d.gen++
+	kernel := []int{}
+	d.stack = append(d.stack[:0], s.kernel...)
+	if d.prog.UTF8 && (c == endOfInput || utf8.RuneStart(byte(c))) {
+		// A match may start before this byte, which begins a rune
+		d.stack = append(d.stack, 0)
+	}
+	for len(d.stack) > 0 {
+		idx := d.stack[len(d.stack)-1]
+		d.stack = d.stack[:len(d.stack)-1]
+		if d.seen[idx] == d.gen {
+			continue
+		}
+		d.seen[idx] = d.gen
+
+		if idx == d.end {
+			s.next[c] = d.matched
+			return d.matched
+		}
+
+		for _, tr := range d.prog.States[idx].Transitions {
+			to := d.prog.Index[tr.To]
+			switch t := tr.Transitioner.(type) {
+			case regex.CharTransitioner:
+				if c != endOfInput && t.Matcher.Match(byte(c)) {
+					kernel = append(kernel, to)
+				}
+			case regex.EpsilonTransitioner:
+				d.stack = append(d.stack, to)
+			case regex.StartOfStringTransitioner:
+				if s.atStart {
+					d.stack = append(d.stack, to)
+				}
+			case regex.EndOfStringTransitioner:
+				if c == endOfInput {
+					d.stack = append(d.stack, to)
+				}
+			case regex.WordBoundaryTransitioner:
+				if (s.prevWord != nextWord) != t.Negate {
+					d.stack = append(d.stack, to)
+				}
+			case regex.NoWordTransitioner:
+				if t.After && !nextWord || !t.After && !s.prevWord {
+					d.stack = append(d.stack, to)
+				}
+			}
+		}
+	}
+
+	if !d.prog.UTF8 {
+		// A match may also start after this byte
+		kernel = append(kernel, 0)
+	}
+	slices.Sort(kernel)
+	kernel = slices.Compact(kernel)
+
+	next := d.intern(kernel, false, nextWord)
+	s.next[c] = next
+
+	return next
+}
+
+// intern returns the cached state for a kernel and context, creating it if needed.
+func (d *DFA) intern(kernel []int, atStart, prevWord bool) *dfaState {
+	d.key = d.key[:0]
+	d.key = append(d.key, boolByte(atStart)<<1|boolByte(prevWord))
+	for _, idx := range kernel {
+		d.key = append(d.key, byte(idx), byte(idx>>8), byte(idx>>16), byte(idx>>24))
+	}
+	if s, ok := d.states[string(d.key)]; ok {
+		return s
+	}
+
+	size := int(unsafe.Sizeof(dfaState{})) + len(kernel)*int(unsafe.Sizeof(0)) + 2*len(d.key)
+	if d.memory+size > d.budget {
+		// States already handed out stay valid but are no longer reachable from the cache,
+		// so the memory they use is released once the search moves past them.
+		d.reset()
+	}
+
+	s := &dfaState{kernel: kernel, atStart: atStart, prevWord: prevWord}
+	d.states[string(d.key)] = s
+	d.memory += size
+
+	return s
+}
+
+func (d *DFA) reset() {
+	d.states = map[string]*dfaState{}
+	d.start = nil
+	d.memory = 0
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package matcher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDFA_AgreesWithMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		inputs  []string
+	}{
+		{pattern: ``, inputs: []string{"", "a"}},
+		{pattern: `a+b`, inputs: []string{"aab", "b", "xaaabx", ""}},
+		{pattern: `^abc`, inputs: []string{"abc", "xabc", "ab"}},
+		{pattern: `abc$`, inputs: []string{"xabc", "abcx", "abc"}},
+		{pattern: `^$`, inputs: []string{"", "a"}},
+		{pattern: `(a*)*b`, inputs: []string{"aaab", "aaa", "b"}},
+		{pattern: `^(\w+)@(\w+)\.com$`, inputs: []string{"me@example.com", "me@example.org"}},
+		{pattern: `ERROR.*timeout`, inputs: []string{"ERROR: read timeout", "ERROR: ok", "timeout ERROR"}},
+		{pattern: `\bcat\b`, inputs: []string{"a cat sat", "concatenate", "cat", "cats"}},
+		{pattern: `\Bat\B`, inputs: []string{"bath", "at", "cat"}},
+		{pattern: `(cat|dog){2,3}s?`, inputs: []string{"catdogcat", "catdog", "cat"}},
+		{pattern: `[^aeiou]{3}`, inputs: []string{"strength", "aeiou"}},
+		{pattern: `x*?y`, inputs: []string{"xxy", "xx"}},
+	}
+
+	for _, tt := range tests {
+		re := compilePattern(t, tt.pattern)
+		dfa, err := NewDFA(re)
+		if err != nil {
+			t.Fatalf("NewDFA(%q) error = %v", tt.pattern, err)
+		}
+
+		for _, input := range tt.inputs {
+			if got, want := dfa.Match([]byte(input)), Match([]byte(input), re); got != want {
+				t.Errorf("DFA.Match(%q) for %q = %v, Match gives %v", input, tt.pattern, got, want)
+			}
+		}
+	}
+}
+
+func TestDFA_SmallMemoryBudget(t *testing.T) {
+	re := compilePattern(t, `(a|b)*a(a|b){4}c`)
+	dfa, err := NewDFA(re)
+	if err != nil {
+		t.Fatalf("NewDFA() error = %v", err)
+	}
+	dfa.WithMemoryBudget(1)
+
+	for _, input := range []string{"abbabbaabac", "abababbbbbc", "bbbbbc", "aaaaaaaaaac"} {
+		if got, want := dfa.Match([]byte(input)), Match([]byte(input), re); got != want {
+			t.Errorf("DFA.Match(%q) = %v, want %v", input, got, want)
+		}
+	}
+	if len(dfa.states) > 1 {
+		t.Errorf("cache holds %d states, want at most 1 with a 1 byte budget", len(dfa.states))
+	}
+}
+
+func TestNewDFA_Unsupported(t *testing.T) {
+	for _, pattern := range []string{`(\w+) \1`, `foo(?=bar)`, `(?<!x)y`} {
+		if _, err := NewDFA(compilePattern(t, pattern)); !errors.Is(err, ErrDFAUnsupported) {
+			t.Errorf("NewDFA(%q) error = %v, want ErrDFAUnsupported", pattern, err)
+		}
+	}
+}
+
+func BenchmarkDFA_LongLine(b *testing.B) {
+	input := []byte(strings.Repeat("lorem ipsum dolor sit amet ", 400) + "error: disk full")
+	re := compilePattern(b, `error: (\w+) (\w+)`)
+
+	b.Run("dfa", func(b *testing.B) {
+		dfa, err := NewDFA(re)
+		if err != nil {
+			b.Fatalf("NewDFA() error = %v", err)
+		}
+		for b.Loop() {
+			dfa.Match(input)
+		}
+	})
+	b.Run("match", func(b *testing.B) {
+		for b.Loop() {
+			Match(input, re)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package matcher
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/grep-starter-go/app/regex"
+)
+
+// FindIndex returns the start and end of the leftmost match of re in input, or nil if
+// there is none.
+func FindIndex(input []byte, re *regex.CompiledRegex) []int {
+	loc := FindSubmatchIndex(input, re)
+	if loc == nil {
+		return nil
+	}
+
+	return loc[:2]
+}
+
+// FindSubmatchIndex returns index pairs for the leftmost match of re in input and its
+// groups, like regexp.Regexp.FindSubmatchIndex: loc[2*i:2*i+2] is the span of group i,
+// in the order of re.SubexpNames, and is -1, -1 for a group that did not take part in
+// the match. It returns nil if there is no match.
+func FindSubmatchIndex(input []byte, re *regex.CompiledRegex) []int {
+	if !hasRequired(re.Program(), input) {
+		return nil
+	}
+
+	return findAt(input, re, 0)
+}
+
+// FindAllIndex returns the spans of successive non-overlapping matches of re in input,
+// at most n of them if n >= 0.
+func FindAllIndex(input []byte, re *regex.CompiledRegex, n int) [][]int {
+	var spans [][]int
+	for _, loc := range FindAllSubmatchIndex(input, re, n) {
+		spans = append(spans, loc[:2])
+	}
+
+	return spans
+}
+
+// FindAllSubmatchIndex returns the submatch indices, as FindSubmatchIndex does, of
+// successive non-overlapping matches of re in input, at most n of them if n >= 0.
+//
+// After an empty match the search resumes one character further on, and an empty match
+// right after the previous match is skipped, so "a*" finds "", "aa" and "" at offsets 0,
+// 1 and 4 of "baac".
+func FindAllSubmatchIndex(input []byte, re *regex.CompiledRegex, n int) [][]int {
+	prog := re.Program()
+	if n == 0 || !hasRequired(prog, input) {
+		return nil
+	}
+
+	var all [][]int
+	prevEnd := -1
+	for pos := 0; pos <= len(input) && (n < 0 || len(all) < n); {
+		loc := findAt(input, re, pos)
+		if loc == nil {
+			break
+		}
+
+		accept := true
+		if loc[1] == loc[0] {
+			if loc[0] == prevEnd {
+				accept = false
+			}
+			// Step over one character so the search makes progress
+			width := 1
+			if prog.UTF8 && loc[1] < len(input) {
+				_, width = utf8.DecodeRune(input[loc[1]:])
+			}
+			pos = loc[1] + width
+		} else {
+			pos = loc[1]
+		}
+		prevEnd = loc[1]
+
+		if accept {
+			all = append(all, loc)
+		}
+	}
+
+	return all
+}
+
+// findAt returns the submatch indices of the leftmost match starting at or after pos.
+func findAt(input []byte, re *regex.CompiledRegex, pos int) []int {
+	prog := re.Program()
+	start := nextCandidate(prog, input, pos)
+	if start == -1 {
+		return nil
+	}
+
+	loc := make([]int, 2*max(len(re.SubexpNames()), 1))
+	for i := range loc {
+		loc[i] = -1
+	}
+
+	if !prog.NeedsBacktracking {
+		vm := newPikeVM(input, re)
+		caps, end, ok := vm.search(start, false)
+		if !ok {
+			return nil
+		}
+		loc[0], loc[1] = caps[capStart], end
+		for i := 1; i < len(loc)/2; i++ {
+			if g, ok := prog.GroupIndex[strconv.Itoa(i)]; ok && caps[capGroups+3*g+1] != -1 {
+				loc[2*i], loc[2*i+1] = caps[capGroups+3*g+1], caps[capGroups+3*g+2]
+			}
+		}
+		return loc
+	}
+
+	for i := start; i != -1; i = nextCandidate(prog, input, i+1) {
+		match := matchAt(i, input, re)
+		if match == nil {
+			continue
+		}
+		loc[0], loc[1] = i, match.end
+		for n := 1; n < len(loc)/2; n++ {
+			if g, ok := match.groups[strconv.Itoa(n)]; ok && g.end != -1 {
+				loc[2*n], loc[2*n+1] = g.start, g.end
+			}
+		}
+		return loc
+	}
+
+	return nil
+}
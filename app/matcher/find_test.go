@@ -0,0 +1,124 @@
+package matcher
+
+import (
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// The standard library also finds leftmost-first matches, so it serves as the reference
+// for patterns both understand.
+func TestFindAllSubmatchIndex_AgreesWithRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		inputs  []string
+	}{
+		{pattern: `a*`, inputs: []string{"baac", "", "aaa", "bbb"}},
+		{pattern: `a+`, inputs: []string{"baac", "abaaba"}},
+		{pattern: `a*?`, inputs: []string{"aa"}},
+		{pattern: `(\w+)@(\w+)\.com`, inputs: []string{"me@x.com, you@y.com", "none"}},
+		{pattern: `(a)|(b)`, inputs: []string{"xaby"}},
+		{pattern: `(a|ab)(c|bcd)(d*)`, inputs: []string{"abcd abcdd"}},
+		{pattern: `\b`, inputs: []string{"ab cd"}},
+		{pattern: `^|$`, inputs: []string{"abc", ""}},
+		{pattern: `x?`, inputs: []string{"axxb"}},
+		{pattern: `(?P<k>\w+)=(?P<v>\w*)`, inputs: []string{"a=1 b= c=3"}},
+		{pattern: `(\d{2,3})+`, inputs: []string{"12345 6 7890"}},
+		{pattern: `(a*)*`, inputs: []string{"b aab"}},
+		{pattern: `(a*)+$`, inputs: []string{"aab"}},
+		{pattern: `(x)?(y)?z`, inputs: []string{"yz", "z", "xyz"}},
+		{pattern: `(a)??b`, inputs: []string{"ab", "b"}},
+		{pattern: `(a){0,2}b`, inputs: []string{"b", "aab"}},
+	}
+
+	for _, tt := range tests {
+		re := compilePattern(t, tt.pattern)
+		std := regexp.MustCompile(tt.pattern)
+		for _, input := range tt.inputs {
+			want := std.FindAllSubmatchIndex([]byte(input), -1)
+			got := FindAllSubmatchIndex([]byte(input), re, -1)
+			if !slices.EqualFunc(got, want, slices.Equal[[]int]) {
+				t.Errorf("FindAllSubmatchIndex(%q, %q) = %v, want %v", input, tt.pattern, got, want)
+			}
+
+			if got, want := FindSubmatchIndex([]byte(input), re), std.FindSubmatchIndex([]byte(input)); !slices.Equal(got, want) {
+				t.Errorf("FindSubmatchIndex(%q, %q) = %v, want %v", input, tt.pattern, got, want)
+			}
+		}
+	}
+}
+
+func TestFindAllIndex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		n       int
+		want    [][]int
+	}{
+		{pattern: `\d+`, input: "a1b22c333", n: -1, want: [][]int{{1, 2}, {3, 5}, {6, 9}}},
+		{pattern: `\d+`, input: "a1b22c333", n: 2, want: [][]int{{1, 2}, {3, 5}}},
+		{pattern: `\d+`, input: "abc", n: -1, want: nil},
+		{pattern: `(\w)\1`, input: "aabbcd ee", n: -1, want: [][]int{{0, 2}, {2, 4}, {7, 9}}},
+		{pattern: `(?<=\$)\d+`, input: "$1 2 $34", n: -1, want: [][]int{{1, 2}, {6, 8}}},
+	}
+
+	for _, tt := range tests {
+		got := FindAllIndex([]byte(tt.input), compilePattern(t, tt.pattern), tt.n)
+		if !slices.EqualFunc(got, tt.want, slices.Equal[[]int]) {
+			t.Errorf("FindAllIndex(%q, %q, %d) = %v, want %v", tt.input, tt.pattern, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFindSubmatchIndex_Backreference(t *testing.T) {
+	re := compilePattern(t, `(?<word>\w+) (x)? ?\k<word>`)
+	got := FindSubmatchIndex([]byte("say hi hi"), re)
+	if want := []int{4, 9, 4, 6, -1, -1}; !slices.Equal(got, want) {
+		t.Errorf("FindSubmatchIndex() = %v, want %v", got, want)
+	}
+	if names := re.SubexpNames(); !slices.Equal(names, []string{"", "word", ""}) {
+		t.Errorf("SubexpNames() = %q", names)
+	}
+	if FindIndex([]byte("say hi ho"), re) != nil {
+		t.Errorf("FindIndex() found a match without a repeated word")
+	}
+}
+
+func TestFindAllSubmatchIndex_UTF8(t *testing.T) {
+	node := compileUnicodePattern(t, `x*`)
+	got := FindAllIndex([]byte("éx"), node, -1)
+	// No empty match inside the two bytes of é
+	if want := [][]int{{0, 0}, {2, 3}}; !slices.EqualFunc(got, want, slices.Equal[[]int]) {
+		t.Errorf("FindAllIndex() = %v, want %v", got, want)
+	}
+}
+
+// A compiled regex is shared by the workers of a parallel search, so the first calls,
+// which build its program, may well be concurrent. Run with -race.
+func TestFindAllSubmatchIndex_ConcurrentUse(t *testing.T) {
+	input := []byte("id=17 x=x id=42 y=yy")
+	for _, pattern := range []string{`(\w+)=(\d+)`, `(\w)=\1`, `(?<=id=)\d+`} {
+		want := FindAllSubmatchIndex(input, compilePattern(t, pattern), -1)
+
+		re := compilePattern(t, pattern)
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 50 {
+					if got := FindAllSubmatchIndex(input, re, -1); !slices.EqualFunc(got, want, slices.Equal[[]int]) {
+						t.Errorf("FindAllSubmatchIndex(%q) = %v, want %v", pattern, got, want)
+						return
+					}
+					if !Match(input, re) {
+						t.Errorf("Match(%q) = false", pattern)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -14,6 +14,14 @@ type searchState struct {
 	epsilonVisited map[*regex.State]bool // To avoid infinite loops on epsilon transitions
 	groups         map[string]GroupMatch // Currently open groups
 	capturedGroups map[string]GroupMatch // All captured groups so far
+	pattern        int                   // Last pattern accepted on the way, or -1
+}
+
+// backtrackMatch is a match found by the backtracking search.
+type backtrackMatch struct {
+	groups  map[string]GroupMatch // Captured groups
+	end     int                   // Position the match ends at
+	pattern int                   // Pattern of a combined regex that matched, or -1
 }
 
 type GroupMatch struct {
@@ -33,6 +41,7 @@ type MatchArg struct {
 	input          []byte
 	pos            int
 	capturedGroups map[string]GroupMatch
+	lookarounds    map[*regex.CompiledRegex]*pikeVM // Pike VMs kept for lookaround bodies
 }
 
 func (m MatchArg) Input() []byte {
@@ -51,9 +60,49 @@ func (m MatchArg) Backreference(name string) (regex.GroupSpan, bool) {
 	return nil, false
 }
 
+// Lookaround runs re anchored at start with the groups captured so far visible to it.
+// Groups captured by a successful assertion become visible to the rest of the match.
+// A body without groups or backreferences runs on the Pike VM, in linear time.
+func (m MatchArg) Lookaround(re *regex.CompiledRegex, start, end int) bool {
+	if !re.Program().NeedsBacktracking && len(re.Program().Groups) == 0 {
+		vm := m.lookarounds[re]
+		if vm == nil {
+			vm = newPikeVM(m.input, re)
+			if m.lookarounds != nil {
+				m.lookarounds[re] = vm
+			}
+		}
+		return vm.matchAnchored(start, end)
+	}
+
+	match := matchWithin(start, end, m.input, re, m.capturedGroups)
+	if match == nil {
+		return false
+	}
+
+	if m.capturedGroups != nil {
+		maps.Copy(m.capturedGroups, match.groups)
+	}
+
+	return true
+}
+
+// Match reports whether re matches anywhere in input. Patterns without backreferences run
+// on the Pike VM in linear time; the rest fall back to the backtracking search.
 func Match(input []byte, re *regex.CompiledRegex) bool {
-	for i := 0; i <= len(input); i++ {
-		if matchedGrp := matchAt(i, input, re); matchedGrp != nil {
+	prog := re.Program()
+	start := firstCandidate(prog, input)
+	if start == -1 {
+		return false
+	}
+
+	if !prog.NeedsBacktracking {
+		_, _, ok := newPikeVM(input, re).search(start, true)
+		return ok
+	}
+
+	for i := start; i != -1; i = nextCandidate(prog, input, i+1) {
+		if match := matchAt(i, input, re); match != nil {
 			return true
 		}
 	}
@@ -61,38 +110,100 @@ func Match(input []byte, re *regex.CompiledRegex) bool {
 	return false
 }
 
+// MatchPattern returns the index of the pattern of a regex built with regex.CompileMulti
+// that has the leftmost match in input, preferring earlier patterns at the same position.
+// It returns -1 if none matches, and 0 for any match of a single-pattern regex.
+func MatchPattern(input []byte, re *regex.CompiledRegex) int {
+	prog := re.Program()
+	start := firstCandidate(prog, input)
+	if start == -1 {
+		return -1
+	}
+
+	if !prog.NeedsBacktracking {
+		caps, _, ok := newPikeVM(input, re).search(start, false)
+		if !ok {
+			return -1
+		}
+		return max(caps[capPattern], 0)
+	}
+
+	for i := start; i != -1; i = nextCandidate(prog, input, i+1) {
+		if match := matchAt(i, input, re); match != nil {
+			return max(match.pattern, 0)
+		}
+	}
+
+	return -1
+}
+
+// MatchWithCaptureGroups returns the groups captured by the leftmost match of re, or nil
+// if there is none.
 func MatchWithCaptureGroups(input []byte, re *regex.CompiledRegex) map[string]string {
+	prog := re.Program()
+	start := firstCandidate(prog, input)
+	if start == -1 {
+		return nil
+	}
+
+	if !prog.NeedsBacktracking {
+		vm := newPikeVM(input, re)
+		caps, _, ok := vm.search(start, false)
+		if !ok {
+			return nil
+		}
+		return groupStrings(input, vm.groups(caps))
+	}
+
 	idsmap := regex.BuildIDMap(re.InitialState())
 	slog.Debug("Target State", "id", idsmap[re.EndingState()])
-	for i := 0; i <= len(input); i++ {
-		if matchedGrp := matchAt(i, input, re); matchedGrp != nil {
+	for i := start; i != -1; i = nextCandidate(prog, input, i+1) {
+		if match := matchAt(i, input, re); match != nil {
 			// Convert GroupMatch to map[string]string
-			slog.Debug("matchAt", "grp", matchedGrp)
-			result := make(map[string]string)
-			for name, match := range matchedGrp {
-				if match.end != -1 { // Ensure the group was closed
-					// Slice the input to get the matched substring
-					result[name] = string(input[match.start:match.end])
-				}
-			}
-
-			return result
+			slog.Debug("matchAt", "grp", match.groups)
+			return groupStrings(input, match.groups)
 		}
 	}
 
 	return nil
 }
 
-func matchAt(i int, input []byte, re *regex.CompiledRegex) map[string]GroupMatch {
+// groupStrings converts closed groups to the substrings of input they matched.
+func groupStrings(input []byte, groups map[string]GroupMatch) map[string]string {
+	result := make(map[string]string)
+	for name, match := range groups {
+		if match.end != -1 { // Ensure the group was closed
+			// Slice the input to get the matched substring
+			result[name] = string(input[match.start:match.end])
+		}
+	}
+
+	return result
+}
+
+func matchAt(i int, input []byte, re *regex.CompiledRegex) *backtrackMatch {
+	return matchWithin(i, -1, input, re, nil)
+}
+
+// matchWithin searches for a match starting at i. If end is not -1 the match must finish
+// exactly at end. captured seeds the groups visible to backreferences.
+func matchWithin(i, end int, input []byte, re *regex.CompiledRegex, captured map[string]GroupMatch) *backtrackMatch {
+	capturedGroups := maps.Clone(captured)
+	if capturedGroups == nil {
+		capturedGroups = map[string]GroupMatch{}
+	}
+
 	stack := []searchState{{
 		idx:            i,
 		state:          re.InitialState(),
-		epsilonVisited: map[*regex.State]bool{},
+		epsilonVisited: map[*regex.State]bool{re.InitialState(): true},
 		groups:         map[string]GroupMatch{},
-		capturedGroups: map[string]GroupMatch{},
+		capturedGroups: capturedGroups,
+		pattern:        -1,
 	}}
 
 	idsmap := regex.BuildIDMap(re.InitialState())
+	lookarounds := map[*regex.CompiledRegex]*pikeVM{}
 
 	for len(stack) > 0 {
 		current := stack[len(stack)-1]
@@ -109,8 +220,12 @@ func matchAt(i int, input []byte, re *regex.CompiledRegex) map[string]GroupMatch
 		}
 		slog.Debug("At", "state", idsmap[current.state], "idx", current.idx, "groups", current.groups)
 
-		if current.state == re.EndingState() {
-			return current.capturedGroups
+		if len(current.state.Accepts) > 0 {
+			current.pattern = current.state.Accepts[0]
+		}
+
+		if current.state == re.EndingState() && (end == -1 || current.idx == end) {
+			return &backtrackMatch{groups: current.capturedGroups, end: current.idx, pattern: current.pattern}
 		}
 
 		// Go through transitions in reverse order to maintain the original order when using a stack
@@ -122,12 +237,14 @@ func matchAt(i int, input []byte, re *regex.CompiledRegex) map[string]GroupMatch
 				input:          input,
 				pos:            current.idx,
 				capturedGroups: capturedGroups,
+				lookarounds:    lookarounds,
 			}
 			if n, ok := tr.Match(arg); ok {
 				if n > 0 { // Non-epsilon transition
-					// Reset epsilon visited on non-epsilon transitions
-					epilonVisited := map[*regex.State]bool{}
-					stack = append(stack, searchState{current.idx + n, tr.To, epilonVisited, groups, capturedGroups})
+					// Reset epsilon visited on non-epsilon transitions. The state reached counts
+					// as visited, so an empty loop iteration cannot come back to it.
+					epilonVisited := map[*regex.State]bool{tr.To: true}
+					stack = append(stack, searchState{current.idx + n, tr.To, epilonVisited, groups, capturedGroups, current.pattern})
 
 					continue
 
@@ -141,7 +258,7 @@ func matchAt(i int, input []byte, re *regex.CompiledRegex) map[string]GroupMatch
 
 				epilonVisited[tr.To] = true
 				//.Don't consume input on epsilon transitions
-				stack = append(stack, searchState{current.idx, tr.To, epilonVisited, groups, capturedGroups})
+				stack = append(stack, searchState{current.idx, tr.To, epilonVisited, groups, capturedGroups, current.pattern})
 			}
 		}
 	}
@@ -2,6 +2,8 @@ package matcher
 
 import (
 	"log/slog"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/codecrafters-io/grep-starter-go/app/parser"
@@ -282,6 +284,58 @@ func TestMatch(t *testing.T) {
 				{input: []byte("x"), want: false},
 			},
 		},
+		{
+			name: "word boundary", // \bcat\b
+			re: func() *regex.CompiledRegex {
+				s := make([]*regex.State, 6)
+				for i := range s {
+					s[i] = regex.NewState()
+				}
+				s[0].AddTransition(s[1], regex.WordBoundaryTransitioner{})
+				s[1].AddTransition(s[2], literalCharTransitioner('c'))
+				s[2].AddTransition(s[3], literalCharTransitioner('a'))
+				s[3].AddTransition(s[4], literalCharTransitioner('t'))
+				s[4].AddTransition(s[5], regex.WordBoundaryTransitioner{})
+
+				re := &regex.CompiledRegex{}
+				re.SetInitialState(s[0])
+				re.SetEndingState(s[5])
+
+				return re
+			},
+			args: []args{
+				{input: []byte("cat"), want: true},
+				{input: []byte("a cat!"), want: true},
+				{input: []byte("(cat)"), want: true},
+				{input: []byte("concat"), want: false},
+				{input: []byte("cats"), want: false},
+				{input: []byte("cat_1"), want: false},
+			},
+		},
+		{
+			name: "non word boundary", // \Bat
+			re: func() *regex.CompiledRegex {
+				s := make([]*regex.State, 4)
+				for i := range s {
+					s[i] = regex.NewState()
+				}
+				s[0].AddTransition(s[1], regex.WordBoundaryTransitioner{Negate: true})
+				s[1].AddTransition(s[2], literalCharTransitioner('a'))
+				s[2].AddTransition(s[3], literalCharTransitioner('t'))
+
+				re := &regex.CompiledRegex{}
+				re.SetInitialState(s[0])
+				re.SetEndingState(s[3])
+
+				return re
+			},
+			args: []args{
+				{input: []byte("cat"), want: true},
+				{input: []byte("at"), want: false},
+				{input: []byte("an at"), want: false},
+				{input: []byte(" at"), want: false},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -459,6 +513,159 @@ func TestMatchWithCaptureGroups(t *testing.T) {
 	}
 }
 
+func TestMatchWithCaptureGroups_Pattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		groups  map[string]string
+	}{
+		{pattern: "(a){3}", input: "aaa", groups: map[string]string{"0": "aaa", "1": "a"}},
+		{pattern: "(\\d+)-(\\w){2,}", input: "12-abc", groups: map[string]string{"0": "12-abc", "1": "12", "2": "c"}},
+		{pattern: "(a|b){1,2}c", input: "abc", groups: map[string]string{"0": "abc", "1": "b"}},
+		{pattern: "(a){0}b", input: "b", groups: map[string]string{"0": "b"}},
+		{pattern: "(?:a)(b)", input: "ab", groups: map[string]string{"0": "ab", "1": "b"}},
+		{pattern: "(?P<year>\\d{4})-(?<month>\\d{2})", input: "2024-05", groups: map[string]string{"0": "2024-05", "1": "2024", "year": "2024", "2": "05", "month": "05"}},
+		// Assertions see groups captured before them, and groups captured inside
+		// a positive assertion are visible afterwards
+		{pattern: "(\\w)(?=\\1)", input: "abb", groups: map[string]string{"0": "b", "1": "b"}},
+		{pattern: "(?=(\\d+))\\w+\\1", input: "123x123", groups: map[string]string{"0": "123x123", "1": "123"}},
+		{pattern: "(?<=(a+))b", input: "aab", groups: map[string]string{"0": "b", "1": "aa"}},
+		{pattern: "(?i)(\\w+) \\1", input: "Hello HELLO", groups: map[string]string{"0": "Hello HELLO", "1": "Hello"}},
+		// Greedy and lazy forms capture different text
+		{pattern: `"(.*)"`, input: `say "hi" and "bye"`, groups: map[string]string{"0": `"hi" and "bye"`, "1": `hi" and "bye`}},
+		{pattern: `"(.*?)"`, input: `say "hi" and "bye"`, groups: map[string]string{"0": `"hi"`, "1": "hi"}},
+		{pattern: "(a+)(a*)", input: "aaa", groups: map[string]string{"0": "aaa", "1": "aaa", "2": ""}},
+		{pattern: "(a+?)(a*)", input: "aaa", groups: map[string]string{"0": "aaa", "1": "a", "2": "aa"}},
+		{pattern: "(a?)(a*)", input: "aa", groups: map[string]string{"0": "aa", "1": "a", "2": "a"}},
+		{pattern: "(a??)(a*)", input: "aa", groups: map[string]string{"0": "aa", "1": "", "2": "aa"}},
+		{pattern: "(a{1,3})(a*)", input: "aaa", groups: map[string]string{"0": "aaa", "1": "aaa", "2": ""}},
+		{pattern: "(a{1,3}?)(a*)", input: "aaa", groups: map[string]string{"0": "aaa", "1": "a", "2": "aa"}},
+		{pattern: "(a{2,}?)(a*)", input: "aaaa", groups: map[string]string{"0": "aaaa", "1": "aa", "2": "aa"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"_"+tt.input, func(t *testing.T) {
+			groups := MatchWithCaptureGroups([]byte(tt.input), compilePattern(t, tt.pattern))
+			if groups == nil {
+				t.Fatalf("MatchWithCaptureGroups() = nil, want %v", tt.groups)
+			}
+
+			if len(groups) != len(tt.groups) {
+				t.Errorf("MatchWithCaptureGroups() groups = %v, want %v", groups, tt.groups)
+			}
+
+			for k, v := range tt.groups {
+				if groups[k] != v {
+					t.Errorf("MatchWithCaptureGroups() groups[%s] = %v, want %v", k, groups[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		input    string
+		want     int
+	}{
+		{patterns: []string{"error", "timeout"}, input: "read timeout", want: 1},
+		{patterns: []string{"error", "timeout"}, input: "timeout error", want: 1},
+		{patterns: []string{"err", "error"}, input: "error", want: 0},
+		{patterns: []string{"error", "timeout"}, input: "ok", want: -1},
+		{patterns: []string{"(a)\\1", "(b)\\1"}, input: "xbb", want: 1},
+		{patterns: []string{"(a)\\1", "(b)\\1"}, input: "abab", want: -1},
+		{patterns: []string{"x"}, input: "x", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.patterns, "|")+"_"+tt.input, func(t *testing.T) {
+			nodes := make([]*parser.RegexNode, len(tt.patterns))
+			for i, pattern := range tt.patterns {
+				node, err := parser.New(pattern).Parse()
+				if err != nil {
+					t.Fatalf("Parse(%q) error = %v", pattern, err)
+				}
+				nodes[i] = node
+			}
+			re, err := regex.CompileMulti(nodes, regex.Options{})
+			if err != nil {
+				t.Fatalf("CompileMulti() error = %v", err)
+			}
+
+			if got := MatchPattern([]byte(tt.input), re); got != tt.want {
+				t.Errorf("MatchPattern() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func compilePattern(t testing.TB, pattern string) *regex.CompiledRegex {
+	t.Helper()
+
+	node, err := parser.New(pattern).Parse()
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", pattern, err)
+	}
+
+	re, err := regex.Compile(node)
+	if err != nil {
+		t.Fatalf("Compile(%q) error = %v", pattern, err)
+	}
+
+	return re
+}
+
+// In UTF-8 mode a match never starts on a continuation byte, which would otherwise be
+// read as an invalid rune.
+func TestMatch_UTF8StartsAtRunes(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    []int // FindIndex result
+	}{
+		{pattern: `[^é]`, input: "é", want: nil},
+		{pattern: `\P{L}`, input: "é", want: nil},
+		{pattern: `\P{L}`, input: "é1", want: []int{2, 3}},
+		{pattern: `\xA9`, input: "é", want: nil},
+		{pattern: `(?<=\P{L})a`, input: "éa", want: nil},
+		{pattern: `(?<=é)a`, input: "éa", want: []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"_"+tt.input, func(t *testing.T) {
+			re := compileUnicodePattern(t, tt.pattern)
+			if got := FindIndex([]byte(tt.input), re); !slices.Equal(got, tt.want) {
+				t.Errorf("FindIndex(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if got := Match([]byte(tt.input), re); got != (tt.want != nil) {
+				t.Errorf("Match(%q) = %v, want %v", tt.input, got, tt.want != nil)
+			}
+			if dfa, err := NewDFA(re); err == nil {
+				if got := dfa.Match([]byte(tt.input)); got != (tt.want != nil) {
+					t.Errorf("DFA.Match(%q) = %v, want %v", tt.input, got, tt.want != nil)
+				}
+			}
+		})
+	}
+}
+
+func compileUnicodePattern(t testing.TB, pattern string) *regex.CompiledRegex {
+	t.Helper()
+
+	node, err := parser.New(pattern).WithUnicode().Parse()
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", pattern, err)
+	}
+
+	re, err := regex.CompileWithOptions(node, regex.Options{UTF8: true})
+	if err != nil {
+		t.Fatalf("Compile(%q) error = %v", pattern, err)
+	}
+
+	return re
+}
+
 func literalCharTransitioner(b byte) regex.CharTransitioner {
 	return regex.CharTransitioner{Matcher: &parser.LiteralMatcher{Char: b}}
 }
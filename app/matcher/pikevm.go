@@ -0,0 +1,249 @@
+package matcher
+
+import (
+	"slices"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/grep-starter-go/app/regex"
+)
+
+// pikeVM simulates all paths through the NFA at once (a Thompson/Pike VM), so a search
+// takes O(len(input) * states) time whatever the pattern. Threads are kept in priority
+// order and only the first thread to reach a state at a position survives, which gives
+// the same leftmost-first match and captures as the backtracking search in matchAt.
+//
+// A lookaround runs its body on a nested Pike VM (see matchAnchored) each time a thread
+// checks it, which multiplies the time by the length the body scans: up to the rest of
+// the input for a lookahead, and up to its width for each start a lookbehind tries.
+//
+// It cannot track backreferences, so Match only uses it when the program allows.
+type pikeVM struct {
+	prog  *regex.Program
+	end   int // Index of the ending state
+	input []byte
+
+	visited []int // Per state, pos+1 of the last position its closure was followed at
+	// Threads still running, in priority order: those arriving at the current position and
+	// those in the middle of a multi-byte UTF-8 sequence, which wait in the list until
+	// they arrive so that threads of every width stay in one order.
+	threads, next []pikeThread
+	// Per arrival position modulo the ring size and per state, pos+1 of the last position
+	// a thread was queued from. A transition consumes at most one UTF-8 sequence, so the
+	// ring covers every target.
+	queued [utf8.UTFMax + 1][]int
+
+	lookarounds map[*regex.CompiledRegex]*pikeVM // Nested VMs for lookaround bodies
+}
+
+// pikeThread is a thread arriving at a state at position at. caps holds the start of the
+// match and the pattern it completed (see capStart and capPattern), followed by three
+// slots per group from capGroups on: the open start, then the captured start and end.
+type pikeThread struct {
+	state int
+	at    int
+	caps  []int
+}
+
+// Layout of the capture slots of a thread
+const (
+	capStart   = 0
+	capPattern = 1
+	capGroups  = 2
+)
+
+// pikeStep is an entry on the closure stack: either the arrival of a thread at a state or,
+// when tr is set, a transition from that state still to be tried.
+type pikeStep struct {
+	state int
+	caps  []int
+	tr    *regex.Transition
+}
+
+func newPikeVM(input []byte, re *regex.CompiledRegex) *pikeVM {
+	prog := re.Program()
+	vm := &pikeVM{
+		prog:    prog,
+		end:     prog.Index[re.EndingState()],
+		input:   input,
+		visited: make([]int, len(prog.States)),
+
+		lookarounds: map[*regex.CompiledRegex]*pikeVM{},
+	}
+	for i := range vm.queued {
+		vm.queued[i] = make([]int, len(prog.States))
+	}
+
+	return vm
+}
+
+// search finds the leftmost-first match starting at or after start and returns its
+// capture slots and end position. With first set it returns as soon as any match is
+// found, which is enough to answer whether the input matches.
+func (vm *pikeVM) search(start int, first bool) ([]int, int, bool) {
+	var matched []int
+	matchEnd := -1
+
+	vm.threads = vm.threads[:0]
+	for pos := start; pos <= len(vm.input); pos++ {
+		if matched == nil && canStart(vm.prog, vm.input, pos) {
+			// A new thread starting here has the lowest priority
+			vm.threads = append(vm.threads, pikeThread{state: vm.prog.Index[vm.prog.States[0]], at: pos, caps: vm.newCaps(pos)})
+		}
+
+		vm.next = vm.next[:0]
+		for _, t := range vm.threads {
+			if t.at > pos {
+				vm.queue(t, pos)
+				continue
+			}
+			if caps, ok := vm.follow(t, pos, true); ok {
+				matched, matchEnd = caps, pos
+				// Lower priority threads, wherever they arrive, can no longer win
+				break
+			}
+		}
+		vm.threads, vm.next = vm.next, vm.threads
+
+		if matched != nil && (first || len(vm.threads) == 0) {
+			break
+		}
+	}
+
+	return matched, matchEnd, matched != nil
+}
+
+// follow walks the epsilon closure of a thread at pos in priority order, queueing threads
+// for consuming transitions. It reports whether the ending state was reached, in which
+// case the rest of the closure is dropped. Unless final is set the ending state is
+// passed over, as a match cannot end at pos.
+func (vm *pikeVM) follow(t pikeThread, pos int, final bool) ([]int, bool) {
+	stack := []pikeStep{{state: t.state, caps: t.caps}}
+	arg := MatchArg{input: vm.input, pos: pos, lookarounds: vm.lookarounds}
+
+	for len(stack) > 0 {
+		step := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if step.tr != nil {
+			n, ok := step.tr.Match(arg)
+			if !ok {
+				continue
+			}
+			to := vm.prog.Index[step.tr.To]
+			if n == 0 {
+				stack = append(stack, pikeStep{state: to, caps: step.caps})
+			} else {
+				vm.queue(pikeThread{state: to, at: pos + n, caps: step.caps}, pos)
+			}
+			continue
+		}
+
+		if vm.visited[step.state] == pos+1 {
+			continue
+		}
+		vm.visited[step.state] = pos + 1
+
+		state := vm.prog.States[step.state]
+		caps := vm.applyGroups(state, step.caps, pos)
+		if step.state == vm.end {
+			if final {
+				return caps, true
+			}
+			continue
+		}
+
+		for i := len(state.Transitions) - 1; i >= 0; i-- {
+			stack = append(stack, pikeStep{state: step.state, caps: caps, tr: &state.Transitions[i]})
+		}
+	}
+
+	return nil, false
+}
+
+// matchAnchored reports whether the program matches starting exactly at start and, if end
+// is not -1, finishing exactly at end. It checks lookaround bodies, so it can be called
+// again on the same input with any positions.
+func (vm *pikeVM) matchAnchored(start, end int) bool {
+	clear(vm.visited)
+	for _, queued := range vm.queued {
+		clear(queued)
+	}
+
+	last := len(vm.input)
+	if end != -1 {
+		last = end
+	}
+	vm.threads = append(vm.threads[:0], pikeThread{state: vm.prog.Index[vm.prog.States[0]], at: start, caps: vm.newCaps(start)})
+	for pos := start; pos <= last && len(vm.threads) > 0; pos++ {
+		vm.next = vm.next[:0]
+		for _, t := range vm.threads {
+			if t.at > pos {
+				vm.queue(t, pos)
+				continue
+			}
+			if _, ok := vm.follow(t, pos, end == -1 || pos == end); ok {
+				return true
+			}
+		}
+		vm.threads, vm.next = vm.next, vm.threads
+	}
+
+	return false
+}
+
+// applyGroups opens and closes the groups marked on a state and records the pattern it
+// accepts, copying caps if it changes.
+func (vm *pikeVM) applyGroups(s *regex.State, caps []int, pos int) []int {
+	if len(s.StartingGroups) == 0 && len(s.EndingGroups) == 0 && len(s.Accepts) == 0 {
+		return caps
+	}
+
+	caps = slices.Clone(caps)
+	for _, grp := range s.StartingGroups {
+		caps[capGroups+3*vm.prog.GroupIndex[grp]] = pos
+	}
+	for _, grp := range s.EndingGroups {
+		i := capGroups + 3*vm.prog.GroupIndex[grp]
+		if caps[i] != -1 {
+			caps[i+1], caps[i+2] = caps[i], pos
+		}
+	}
+	if len(s.Accepts) > 0 {
+		caps[capPattern] = s.Accepts[0]
+	}
+
+	return caps
+}
+
+func (vm *pikeVM) newCaps(start int) []int {
+	caps := make([]int, capGroups+3*len(vm.prog.Groups))
+	for i := range caps {
+		caps[i] = -1
+	}
+	caps[capStart] = start
+
+	return caps
+}
+
+// queue adds t to the threads for the step after pos, unless a thread of higher priority
+// already heads to the same state and position.
+func (vm *pikeVM) queue(t pikeThread, pos int) {
+	queued := vm.queued[t.at%len(vm.queued)]
+	if queued[t.state] == pos+1 {
+		return
+	}
+	queued[t.state] = pos + 1
+	vm.next = append(vm.next, t)
+}
+
+// groups converts capture slots to the captured groups keyed by group name.
+func (vm *pikeVM) groups(caps []int) map[string]GroupMatch {
+	result := map[string]GroupMatch{}
+	for i, name := range vm.prog.Groups {
+		if start := caps[capGroups+3*i+1]; start != -1 {
+			result[name] = GroupMatch{start, caps[capGroups+3*i+2]}
+		}
+	}
+
+	return result
+}
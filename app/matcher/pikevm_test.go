@@ -0,0 +1,111 @@
+package matcher
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/grep-starter-go/app/regex"
+)
+
+// backtrackCaptureGroups runs the backtracking search directly, for comparison.
+func backtrackCaptureGroups(input []byte, re *regex.CompiledRegex) map[string]string {
+	for i := 0; i <= len(input); i++ {
+		if match := matchAt(i, input, re); match != nil {
+			return groupStrings(input, match.groups)
+		}
+	}
+
+	return nil
+}
+
+func TestPikeVM_AgreesWithBacktracking(t *testing.T) {
+	tests := []struct {
+		pattern string
+		inputs  []string
+		utf8    bool
+	}{
+		{pattern: `a+b`, inputs: []string{"aab", "b", "xaaabx", ""}},
+		{pattern: `^(\w+)@(\w+)\.com$`, inputs: []string{"me@example.com", "me@example.org"}},
+		{pattern: `(a|ab)(c|bcd)(d*)`, inputs: []string{"abcd", "abcdd", "acd"}},
+		{pattern: `(a*)*b`, inputs: []string{"aaab", "aaa", "b"}},
+		{pattern: `(a*)+$`, inputs: []string{"aa", "ba", ""}},
+		{pattern: `(a+?)(a*)`, inputs: []string{"aaaa"}},
+		{pattern: `<(.+)>`, inputs: []string{"<a><b>"}},
+		{pattern: `<(.+?)>`, inputs: []string{"<a><b>"}},
+		{pattern: `(?P<year>\d{4})-(?P<month>\d\d)?`, inputs: []string{"on 2024-06-01", "2024-x"}},
+		{pattern: `(x)?(y)?z`, inputs: []string{"yz", "xz", "z"}},
+		{pattern: `((a)|b)+`, inputs: []string{"ab", "ba"}},
+		{pattern: `\bcat\b`, inputs: []string{"a cat sat", "concatenate"}},
+		{pattern: `foo(?=bar)`, inputs: []string{"foobar", "foobaz"}},
+		{pattern: `(?<=\$)\d+`, inputs: []string{"cost $42", "cost 42"}},
+		{pattern: `(cat|dog){2,3}`, inputs: []string{"catdogcat", "catdog", "cat"}},
+		{pattern: `(?<=a|ab)c`, inputs: []string{"abc", "xbc"}},
+		{pattern: `(?<!a|ab)c`, inputs: []string{"abc", "xbc"}},
+		{pattern: `x|(?=(?:a*)*b)`, inputs: []string{strings.Repeat("a", 40), strings.Repeat("a", 40) + "b"}},
+		{pattern: `(b?)*.`, inputs: []string{"bba", "a"}},
+		{pattern: `(a|b)(a?)+`, inputs: []string{"xaab", "b"}},
+		{pattern: `(a|)*b`, inputs: []string{"aab"}},
+		{pattern: `(\xC3)|(.)`, inputs: []string{"é", "xé"}, utf8: true},
+		{pattern: `(.)|(\xC3)`, inputs: []string{"é"}, utf8: true},
+		{pattern: `(\xC3\xA9|.)(.?)`, inputs: []string{"éé"}, utf8: true},
+	}
+
+	for _, tt := range tests {
+		re := compilePattern(t, tt.pattern)
+		if tt.utf8 {
+			re = compileUnicodePattern(t, tt.pattern)
+		}
+		if re.Program().NeedsBacktracking {
+			t.Fatalf("%q unexpectedly needs backtracking", tt.pattern)
+		}
+
+		for _, input := range tt.inputs {
+			want := backtrackCaptureGroups([]byte(input), re)
+			got := MatchWithCaptureGroups([]byte(input), re)
+			if !maps.Equal(got, want) || (got == nil) != (want == nil) {
+				t.Errorf("MatchWithCaptureGroups(%q, %q) = %v, backtracking gives %v", input, tt.pattern, got, want)
+			}
+			if got, want := Match([]byte(input), re), want != nil; got != want {
+				t.Errorf("Match(%q, %q) = %v, want %v", input, tt.pattern, got, want)
+			}
+		}
+	}
+}
+
+func TestMatch_Backreference_UsesBacktracking(t *testing.T) {
+	re := compilePattern(t, `(\w+) \1`)
+	if !re.Program().NeedsBacktracking {
+		t.Fatalf("backreference pattern should need backtracking")
+	}
+
+	got := MatchWithCaptureGroups([]byte("say hello hello"), re)
+	if got["1"] != "hello" {
+		t.Errorf("MatchWithCaptureGroups() group 1 = %q, want %q", got["1"], "hello")
+	}
+}
+
+func BenchmarkMatch_Pathological(b *testing.B) {
+	input := []byte(strings.Repeat("a", 14))
+	re := compilePattern(b, `(a*)*b`)
+
+	b.Run("pikevm", func(b *testing.B) {
+		for b.Loop() {
+			Match(input, re)
+		}
+	})
+	b.Run("backtracking", func(b *testing.B) {
+		for b.Loop() {
+			backtrackCaptureGroups(input, re)
+		}
+	})
+}
+
+func BenchmarkMatch_LongLine(b *testing.B) {
+	input := []byte(strings.Repeat("lorem ipsum dolor sit amet ", 400) + "error: disk full")
+	re := compilePattern(b, `error: (\w+) (\w+)`)
+
+	for b.Loop() {
+		Match(input, re)
+	}
+}
@@ -0,0 +1,54 @@
+package matcher
+
+import (
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/grep-starter-go/app/regex"
+)
+
+// firstCandidate returns the first position a match of prog can start at, or -1 when the
+// input lacks a literal every match contains.
+func firstCandidate(prog *regex.Program, input []byte) int {
+	if !hasRequired(prog, input) {
+		return -1
+	}
+
+	return nextCandidate(prog, input, 0)
+}
+
+// hasRequired reports whether input contains the required literal of prog, if it has one.
+func hasRequired(prog *regex.Program, input []byte) bool {
+	// Searching for the prefix rejects the input as well, so the required literal is only
+	// worth a separate search when it is longer
+	if prog.Required != nil && (prog.Prefix == nil || len(prog.Required.Pattern()) > len(prog.Prefix.Pattern())) {
+		return prog.Required.Index(input) != -1
+	}
+
+	return true
+}
+
+// nextCandidate returns the first position at or after from where a match of prog can
+// start, or -1 if there is none.
+func nextCandidate(prog *regex.Program, input []byte, from int) int {
+	for from <= len(input) {
+		if prog.Prefix != nil {
+			i := prog.Prefix.Index(input[from:])
+			if i == -1 {
+				return -1
+			}
+			from += i
+		}
+		if canStart(prog, input, from) {
+			return from
+		}
+		from++
+	}
+
+	return -1
+}
+
+// canStart reports whether a match of prog may start at pos: anywhere, unless prog works
+// on UTF-8 text, where matches start at the first byte of a rune.
+func canStart(prog *regex.Program, input []byte, pos int) bool {
+	return !prog.UTF8 || pos == len(input) || utf8.RuneStart(input[pos])
+}
@@ -0,0 +1,33 @@
+package matcher
+
+import "testing"
+
+func TestMatch_Prefilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{pattern: `\bfoo`, input: "xfoo foo", want: true},
+		{pattern: `\bfoo`, input: "xfoo", want: false},
+		{pattern: `\Bfoo`, input: "foo xfoo", want: true},
+		{pattern: `^foo`, input: "xfoo", want: false},
+		{pattern: `(?<=a)bc`, input: "xbc abc", want: true},
+		{pattern: `ERROR.*timeout`, input: "ERROR: disk full", want: false},
+		{pattern: `ERROR.*timeout`, input: "WARN timeout, ERROR timeout", want: true},
+		{pattern: `(\w+) \1 end`, input: "say hi hi end", want: true},
+		{pattern: `(\w+) \1 end`, input: "say hi ho end", want: false},
+	}
+
+	for _, tt := range tests {
+		re := compilePattern(t, tt.pattern)
+		if got := Match([]byte(tt.input), re); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.input, tt.pattern, got, tt.want)
+		}
+		if dfa, err := NewDFA(re); err == nil {
+			if got := dfa.Match([]byte(tt.input)); got != tt.want {
+				t.Errorf("DFA.Match(%q, %q) = %v, want %v", tt.input, tt.pattern, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// fileJob is a file for a worker to search, or an error met while walking the paths.
+type fileJob struct {
+	seq  int // Position of the file in walk order
+	path string
+	err  error
+}
+
+// fileResult is the outcome of searching one file.
+type fileResult struct {
+	seq     int
+	out     []byte // Everything printed for the file
+	matched bool
+	groups  bool // Whether context groups were printed, so the first needs a separator
+	stats   jsonStats
+	err     error
+}
+
+// searchParallel searches paths for -r, walking the directories among them, with
+// opts.jobs workers. Each worker buffers the output of a file and it is written to s.out
+// in one piece, as soon as the file is done or with --sort path in walk order. An error
+// is passed to onError in its turn and the search goes on. Returns whether any line was
+// selected, or with -L any file listed; with -q it returns at the first selected line.
+func (s *searcher) searchParallel(paths []string, onError func(error)) bool {
+	if s.opts.json {
+		s.report.start = time.Now()
+	}
+
+	// Closed on return to stop the walker and the workers
+	done := make(chan struct{})
+	defer close(done)
+
+	jobs := make(chan fileJob)
+	go s.walk(paths, jobs, done)
+
+	workers := s.opts.jobs
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	results := make(chan fileResult)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(jobs, results, done)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	found := false
+	emit := func(res fileResult) {
+		if res.err != nil {
+			onError(res.err)
+			return
+		}
+		if res.groups {
+			// A group from another file came before
+			s.separate(0, 1)
+		}
+		s.out.Write(res.out)
+		s.report.total.add(res.stats)
+		found = found || res.matched
+	}
+
+	pending := map[int]fileResult{} // Results waiting for their turn with --sort path
+	next := 0
+	for res := range results {
+		if !s.opts.sortPath {
+			emit(res)
+		} else {
+			pending[res.seq] = res
+			for r, ok := pending[next]; ok; r, ok = pending[next] {
+				delete(pending, next)
+				next++
+				emit(r)
+			}
+		}
+		if found && s.opts.quiet {
+			return true
+		}
+	}
+	return found
+}
+
+// walk sends the files to search in paths to jobs, in lexical order within each
+// directory, until done is closed. Only regular files are searched in directories. Paths
+// and directories that cannot be read are sent as errors and skipped.
+func (s *searcher) walk(paths []string, jobs chan<- fileJob, done <-chan struct{}) {
+	defer close(jobs)
+
+	seq := 0
+	send := func(path string, err error) bool {
+		select {
+		case jobs <- fileJob{seq: seq, path: path, err: err}:
+			seq++
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	for _, p := range paths {
+		if p == stdinPath {
+			if !send(p, nil) {
+				return
+			}
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			if !send("", fmt.Errorf("stat path %s: %w", p, err)) {
+				return
+			}
+			continue
+		}
+		if !info.IsDir() {
+			if !send(p, nil) {
+				return
+			}
+			continue
+		}
+
+		stopped := false
+		filepath.WalkDir(p, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				if !send("", fmt.Errorf("walk path %s: %w", path, walkErr)) {
+					stopped = true
+					return filepath.SkipAll
+				}
+				return nil
+			}
+			if d.IsDir() || !d.Type().IsRegular() {
+				return nil
+			}
+			if !send(path, nil) {
+				stopped = true
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+// work searches the files from jobs and sends their results until jobs is closed or done
+// is. Each worker has its own matcher, as the DFA behind a regex matcher is not safe for
+// concurrent use; the compiled regex itself is shared.
+func (s *searcher) work(jobs <-chan fileJob, results chan<- fileResult, done <-chan struct{}) {
+	match := s.match.Clone()
+	for job := range jobs {
+		res := fileResult{seq: job.seq, err: job.err}
+		if job.err == nil {
+			out := &bytes.Buffer{}
+			fs := &searcher{opts: s.opts, match: match, group: s.group, out: out, colors: s.colors}
+			res.matched, res.err = fs.processFile(job.path, true)
+			if res.err != nil {
+				if job.path == stdinPath {
+					res.err = fmt.Errorf("read %s: %w", s.opts.label, res.err)
+				} else {
+					res.err = fmt.Errorf("process file %s: %w", job.path, res.err)
+				}
+			}
+			res.out, res.groups, res.stats = out.Bytes(), fs.printedGroup, fs.report.total
+		}
+
+		select {
+		case results <- res:
+		case <-done:
+			return
+		}
+	}
+}
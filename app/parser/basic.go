@@ -0,0 +1,140 @@
+package parser
+
+import "strings"
+
+// WithBasic makes the parser read the pattern as a POSIX basic regular expression (BRE),
+// as grep does without -E. There '\(', '\)', '\{', '\}', '\|', '\+' and '\?' are the
+// operators while the bare characters are literals, '*' at the start of an expression is
+// a literal, and '^' and '$' are anchors only at the start and end of an expression.
+func (p *Parser) WithBasic() *Parser {
+	p.basic = true
+
+	return p
+}
+
+// basicRewriter builds the extended form of a basic regular expression, recording for
+// each byte written the position in the basic pattern it comes from.
+type basicRewriter struct {
+	b       strings.Builder
+	offsets []int
+}
+
+// write writes s for the basic pattern element at from.
+func (w *basicRewriter) write(from int, s string) {
+	w.b.WriteString(s)
+	for range len(s) {
+		w.offsets = append(w.offsets, from)
+	}
+}
+
+// copy writes pattern[from:to] unchanged.
+func (w *basicRewriter) copy(pattern string, from, to int) {
+	w.b.WriteString(pattern[from:to])
+	for i := from; i < to; i++ {
+		w.offsets = append(w.offsets, i)
+	}
+}
+
+// basicToExtended rewrites a basic regular expression into the equivalent extended one,
+// so both dialects share the rest of the parser and produce the same tree. offsets maps
+// each position in the result, and its end, to the position in pattern it comes from.
+func basicToExtended(pattern string) (extended string, offsets []int) {
+	var w basicRewriter
+	atStart := true // At the start of an expression, where '*' is literal and '^' an anchor
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(pattern) {
+				// Leave the dangling escape for the parser to report
+				w.copy(pattern, i, i+1)
+				continue
+			}
+			i++
+			esc := pattern[i]
+			switch esc {
+			case '(', '|':
+				w.write(i-1, string(esc))
+				atStart = true
+				continue
+			case ')', '{', '}', '+', '?':
+				w.write(i-1, string(esc))
+			default:
+				w.copy(pattern, i-1, i+1)
+			}
+		case '(', ')', '{', '}', '|', '+', '?':
+			w.write(i, `\`+string(c))
+		case '*':
+			if atStart {
+				w.write(i, `\*`)
+			} else {
+				w.copy(pattern, i, i+1)
+			}
+		case '^':
+			if atStart {
+				w.copy(pattern, i, i+1)
+				// A '*' right after a leading anchor is still literal
+				continue
+			}
+			w.write(i, `\^`)
+		case '$':
+			rest := pattern[i+1:]
+			if rest == "" || strings.HasPrefix(rest, `\)`) || strings.HasPrefix(rest, `\|`) {
+				w.copy(pattern, i, i+1)
+			} else {
+				w.write(i, `\$`)
+			}
+		case '[':
+			i = copyBracket(&w, pattern, i)
+		default:
+			w.copy(pattern, i, i+1)
+		}
+		atStart = false
+	}
+
+	return w.b.String(), append(w.offsets, len(pattern))
+}
+
+// copyBracket copies the bracket expression starting at pattern[start] and returns the
+// index of its closing ']'. Backslashes are literal inside a POSIX bracket expression, so
+// they are escaped for the extended parser.
+func copyBracket(w *basicRewriter, pattern string, start int) int {
+	w.copy(pattern, start, start+1)
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		w.copy(pattern, i, i+1)
+		i++
+	}
+	// A ']' first in the list is a literal
+	if i < len(pattern) && pattern[i] == ']' {
+		w.copy(pattern, i, i+1)
+		i++
+	}
+
+	for ; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == ']':
+			w.copy(pattern, i, i+1)
+			return i
+		case c == '\\':
+			w.write(i, `\\`)
+		case c == '[' && i+1 < len(pattern) && strings.IndexByte(":.=", pattern[i+1]) != -1:
+			// Copy [:class:] and friends whole so their ']' does not close the list
+			delim := pattern[i+1]
+			end := strings.Index(pattern[i+2:], string(delim)+"]")
+			if end == -1 {
+				w.copy(pattern, i, i+1)
+				continue
+			}
+			w.copy(pattern, i, i+2+end+2)
+			i += 2 + end + 1
+		default:
+			w.copy(pattern, i, i+1)
+		}
+	}
+
+	// Unterminated, left for the parser to report
+	return i - 1
+}
@@ -0,0 +1,75 @@
+package parser
+
+import "testing"
+
+func TestParser_Parse_BasicDialect(t *testing.T) {
+	tests := []struct {
+		basic    string
+		extended string // Pattern giving the same tree in the extended dialect
+	}{
+		{basic: `a\(b\)c`, extended: `a(b)c`},
+		{basic: `(a)`, extended: `\(a\)`},
+		{basic: `a\{2,3\}`, extended: `a{2,3}`},
+		{basic: `a{2}`, extended: `a\{2\}`},
+		{basic: `cat\|dog`, extended: `cat|dog`},
+		{basic: `a|b`, extended: `a\|b`},
+		{basic: `a\+b\?`, extended: `a+b?`},
+		{basic: `a+b?`, extended: `a\+b\?`},
+		{basic: `*a`, extended: `\*a`},
+		{basic: `^*a`, extended: `^\*a`},
+		{basic: `\(*a\)`, extended: `(\*a)`},
+		{basic: `a*`, extended: `a*`},
+		{basic: `a^b$c`, extended: `a\^b\$c`},
+		{basic: `^ab$`, extended: `^ab$`},
+		{basic: `\(^a$\)`, extended: `(^a$)`},
+		{basic: `\(a\)\1`, extended: `(a)\1`},
+		{basic: `[(|)]`, extended: `[(|)]`},
+		{basic: `[\n]`, extended: `[\\n]`},
+		{basic: `[]a]`, extended: `[]a]`},
+		{basic: `[[:digit:]]\+`, extended: `[[:digit:]]+`},
+		{basic: `\.\*`, extended: `\.\*`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.basic, func(t *testing.T) {
+			got, err := New(tt.basic).WithBasic().Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			want, err := New(tt.extended).Parse()
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.extended, err)
+			}
+			if !nodesEqual(got, want) {
+				t.Errorf("Parse() = %+v, want the tree of %q", got, tt.extended)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_BasicDialectErrors(t *testing.T) {
+	for _, pattern := range []string{`\(a`, `[a`, `a\{2`, `a\`} {
+		if _, err := New(pattern).WithBasic().Parse(); err == nil {
+			t.Errorf("Parse(%q) expected error", pattern)
+		}
+	}
+}
+
+func TestParser_Parse_BasicDialectErrorPositions(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: `x+\(\?x\)`, want: "unsupported group syntax '(?' at position 2"},
+		{pattern: `(a)+b\{3`, want: "unterminated repetition '{' at position 5"},
+		{pattern: `[\]a\{2,1\}`, want: "invalid repetition range {2,1} at position 4"},
+		{pattern: `a+[[:nope:]]`, want: "unknown POSIX class \"nope\" at position 3"},
+	}
+
+	for _, tt := range tests {
+		_, err := New(tt.pattern).WithBasic().Parse()
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.pattern, err, tt.want)
+		}
+	}
+}
@@ -1,20 +1,51 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxRepeat bounds the counts accepted in '{n,m}' since each repetition is expanded in the NFA.
+const maxRepeat = 1000
+
+// maxExpansion bounds the number of nodes a repetition stands for once expanded, as nested
+// repetitions multiply: ((a{1000}){1000}){1000} would otherwise expand to 10^9 copies.
+const maxExpansion = 100_000
 
 type Parser struct {
-	pattern string
-	pos     int
+	pattern  string
+	pos      int
+	names    map[string]bool // Named groups seen so far
+	groups   int             // Capturing groups opened so far
+	caseMode CaseMode        // Case flag from the innermost inline (?i) or (?-i)
+	unicode  bool            // Treat the pattern and classes as UTF-8 runes
+	basic    bool            // Read the pattern as a POSIX basic regular expression
+	offsets  []int           // For a basic pattern, the position each byte of its extended form comes from
 }
 
 func New(pattern string) *Parser {
 	return &Parser{
 		pattern: pattern,
 		pos:     0,
+		names:   map[string]bool{},
 	}
 }
 
+// WithUnicode switches the parser to UTF-8 mode: '.', shorthands and character classes
+// match a whole UTF-8 encoded rune and classes may hold runes and \p{...} properties.
+func (p *Parser) WithUnicode() *Parser {
+	p.unicode = true
+
+	return p
+}
+
 func (p *Parser) Parse() (*RegexNode, error) {
+	if p.basic {
+		p.pattern, p.offsets = basicToExtended(p.pattern)
+	}
+
 	// Parse the whole pattern and wrap it in a top-level capturing group
 	alt, seq, err := p.parseAlternation('\x00') // no explicit stop char
 	if err != nil {
@@ -23,7 +54,7 @@ func (p *Parser) Parse() (*RegexNode, error) {
 
 	// Ensure entire pattern was consumed
 	if p.pos < len(p.pattern) {
-		return nil, fmt.Errorf("unexpected character '%c' at position %d", p.pattern[p.pos], p.pos)
+		return nil, fmt.Errorf("unexpected character '%c' at position %d", p.pattern[p.pos], p.position(p.pos))
 	}
 
 	// Top-level must be a capturing group
@@ -116,7 +147,7 @@ func (p *Parser) parseTerm(stop byte) (*RegexNode, error) {
 		node = NewDollarAnchor()
 	case '.':
 		p.next()
-		node = NewCharGroupMatch(WildcardMatcher)
+		node = NewCharGroupMatch(p.set(WildcardMatcher))
 	case '(':
 		n, err := p.parseGroup()
 		if err != nil {
@@ -130,13 +161,14 @@ func (p *Parser) parseTerm(stop byte) (*RegexNode, error) {
 		}
 		node = n
 	case '\\':
+		start := p.pos
 		p.next()
 		if p.eof() {
 			return nil, fmt.Errorf("incomplete escape at end of pattern")
 		}
 		esc := p.next()
-		// Backreference: \\ followed by one or more digits
-		if esc >= '0' && esc <= '9' {
+		// Backreference: \\ followed by one or more digits (\\0 starts an octal escape)
+		if esc >= '1' && esc <= '9' {
 			buf := []byte{esc}
 			for {
 				c2 := p.peek()
@@ -145,25 +177,83 @@ func (p *Parser) parseTerm(stop byte) (*RegexNode, error) {
 				}
 				buf = append(buf, p.next())
 			}
+			// A reference must follow the group it refers to
+			if n, err := strconv.Atoi(string(buf)); err != nil || n > p.groups {
+				return nil, fmt.Errorf("backreference to missing group %s at position %d", buf, p.position(start))
+			}
 			node = NewBackreference(string(buf))
 			break
 		}
 
 		switch esc {
-		case 'd':
-			node = NewCharGroupMatch(DigitMatcher)
-		case 'w':
-			node = NewCharGroupMatch(WordMatcher)
+		case 'k':
+			name, err := p.parseGroupName('<', '>')
+			if err != nil {
+				return nil, fmt.Errorf("invalid named backreference: %w", err)
+			}
+			if !p.names[name] {
+				return nil, fmt.Errorf("backreference to missing group %q at position %d", name, p.position(start))
+			}
+			node = NewBackreference(name)
+		case 'b':
+			node = NewWordBoundary()
+		case 'B':
+			node = NewNonWordBoundary()
+		case 'p', 'P':
+			m, err := p.parseUnicodeClass(esc)
+			if err != nil {
+				return nil, err
+			}
+			node = NewCharGroupMatch(m)
 		default:
-			node = NewLiteralMatch(esc)
+			if m := shorthandMatcher(esc); m != nil {
+				node = NewCharGroupMatch(p.set(m))
+				break
+			}
+			b, err := p.parseByteEscape(esc)
+			if err != nil {
+				return nil, err
+			}
+			node = NewLiteralMatch(b)
 		}
 	default:
-		// literal character
+		// literal character; in UTF-8 mode a multibyte character is matched as one rune
+		if r, size := p.peekRune(); size > 1 {
+			p.pos += size
+			node = NewCharGroupMatch(p.set(&CharGroupMatcher{RuneRanges: [][2]rune{{r, r}}, Label: string(r)}))
+			break
+		}
 		p.next()
 		node = NewLiteralMatch(c)
 	}
 
-	// Parse optional quantifier
+	if node.Type == NodeTypeMatch || node.Type == NodeTypeBackreference {
+		node.Case = p.caseMode
+	}
+
+	if err := p.parseQuantifier(node); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// parseQuantifier parses an optional quantifier following a term: '+', '*', '?' or '{n}', '{n,}', '{n,m}'.
+// A trailing '?' makes the quantifier lazy.
+func (p *Parser) parseQuantifier(node *RegexNode) error {
+	if err := p.parseGreedyQuantifier(node); err != nil {
+		return err
+	}
+
+	if node.Quantifier != 0 && p.peek() == '?' {
+		node.Quantifier |= QuantifierLazy
+		p.next()
+	}
+
+	return nil
+}
+
+func (p *Parser) parseGreedyQuantifier(node *RegexNode) error {
 	switch p.peek() {
 	case '+':
 		node.Quantifier |= QuantifierPlus
@@ -174,58 +264,284 @@ func (p *Parser) parseTerm(stop byte) (*RegexNode, error) {
 	case '?':
 		node.Quantifier |= QuantifierOptional
 		p.next()
+	case '{':
+		// A brace not followed by a digit is a literal, e.g. "a{" or "{x}"
+		if p.pos+1 >= len(p.pattern) || !isDigit(p.pattern[p.pos+1]) {
+			return nil
+		}
+		return p.parseRange(node)
 	}
 
-	return node, nil
+	return nil
+}
+
+// parseRange parses a bounded repetition '{n}', '{n,}' or '{n,m}'.
+func (p *Parser) parseRange(node *RegexNode) error {
+	start := p.pos
+	p.next() // consume '{'
+
+	min, ok := p.parseInt()
+	if !ok {
+		return fmt.Errorf("invalid repetition count at position %d", p.position(p.pos))
+	}
+
+	max := min
+	if p.peek() == ',' {
+		p.next()
+		max = -1
+		if isDigit(p.peek()) {
+			max, ok = p.parseInt()
+			if !ok {
+				return fmt.Errorf("invalid repetition count at position %d", p.position(p.pos))
+			}
+		}
+	}
+
+	if p.peek() != '}' {
+		return fmt.Errorf("unterminated repetition '{' at position %d", p.position(start))
+	}
+	p.next() // consume '}'
+
+	if max != -1 && max < min {
+		return fmt.Errorf("invalid repetition range {%d,%d} at position %d", min, max, p.position(start))
+	}
+	if min > maxRepeat || max > maxRepeat {
+		return fmt.Errorf("repetition count exceeds %d at position %d", maxRepeat, p.position(start))
+	}
+
+	node.WithRange(min, max)
+	if expandedSize(node, maxExpansion) > maxExpansion {
+		return fmt.Errorf("repetition too large at position %d", p.position(start))
+	}
+
+	return nil
+}
+
+// expandedSize returns the number of nodes n stands for once its bounded repetitions are
+// expanded into copies, or limit+1 if that is more than limit.
+func expandedSize(n *RegexNode, limit int) int {
+	size := 1
+	for _, child := range n.Children {
+		size += expandedSize(child, limit)
+		if size > limit {
+			return limit + 1
+		}
+	}
+	if n.Quantifier.Range() {
+		copies := n.Max
+		if n.Max == -1 {
+			copies = n.Min + 1 // the last copy is starred
+		}
+		if copies > 0 && size > limit/copies {
+			return limit + 1
+		}
+		size *= max(copies, 1)
+	}
+
+	return size
 }
 
-// parseGroup parses a capturing group: '(' ... ')'
+// parseInt consumes a run of decimal digits.
+func (p *Parser) parseInt() (int, bool) {
+	if !isDigit(p.peek()) {
+		return 0, false
+	}
+
+	n := 0
+	for isDigit(p.peek()) {
+		n = n*10 + int(p.next()-'0')
+		if n > maxRepeat {
+			// Keep consuming so the error position points past the number
+			n = maxRepeat + 1
+		}
+	}
+
+	return n, true
+}
+
+// parseGroup parses a group: '(' ... ')'. Besides plain capturing groups it supports
+// non-capturing groups '(?:...)', named groups '(?P<name>...)' or '(?<name>...)',
+// lookaround assertions '(?=...)', '(?!...)', '(?<=...)' and '(?<!...)', and inline
+// case flags '(?i)', '(?-i)' or scoped '(?i:...)'.
 func (p *Parser) parseGroup() (*RegexNode, error) {
 	// consume '('
 	if p.next() != '(' {
-		return nil, fmt.Errorf("expected '(' at position %d", p.pos-1)
+		return nil, fmt.Errorf("expected '(' at position %d", p.position(p.pos-1))
+	}
+
+	// Inline flags set inside the group end with it
+	saved := p.caseMode
+	defer func() { p.caseMode = saved }()
+
+	capturing := true
+	name := ""
+	if p.peek() == '?' {
+		p.next()
+		switch {
+		case p.peek() == '=' || p.peek() == '!' || p.lookingAt("<=") || p.lookingAt("<!"):
+			return p.parseLookaround()
+		case p.peek() == ':':
+			p.next()
+			capturing = false
+		case p.peek() == 'P' || p.peek() == '<':
+			if p.peek() == 'P' {
+				p.next()
+			}
+			start := p.pos
+			n, err := p.parseGroupName('<', '>')
+			if err != nil {
+				return nil, fmt.Errorf("invalid group name: %w", err)
+			}
+			if p.names[n] {
+				return nil, fmt.Errorf("duplicate group name %q at position %d", n, p.position(start))
+			}
+			p.names[n] = true
+			name = n
+		case p.peek() == 'i' || p.peek() == '-':
+			mode, scoped, err := p.parseFlags()
+			if err != nil {
+				return nil, err
+			}
+			if !scoped {
+				// (?i) applies to the rest of the enclosing group
+				saved = mode
+				return NewGroup(nil), nil
+			}
+			p.caseMode = mode
+			capturing = false
+		default:
+			return nil, fmt.Errorf("unsupported group syntax '(?' at position %d", p.position(p.pos-2))
+		}
 	}
 
+	if capturing {
+		p.groups++
+	}
 	alt, seq, err := p.parseAlternation(')')
 	if err != nil {
 		return nil, err
 	}
 
 	if p.peek() != ')' {
-		return nil, fmt.Errorf("unmatched '(' at position %d", p.pos-1)
+		return nil, fmt.Errorf("unmatched '(' at position %d", p.position(p.pos-1))
 	}
 	// consume ')'
 	p.next()
 
 	var node *RegexNode
 	if alt != nil {
-		alt.Capturing = true
 		node = alt
 	} else {
 		node = NewGroup(seq)
-		node.Capturing = true
 	}
+	node.Capturing = capturing
+	node.GroupName = name
 
-	// optional quantifier after group
-	switch p.peek() {
-	case '+':
-		node.Quantifier |= QuantifierPlus
-		p.next()
-	case '*':
-		node.Quantifier |= QuantifierAsterisk
+	return node, nil
+}
+
+// parseFlags parses inline flags after '(?' up to and including ')' or ':'. Only the
+// case-insensitivity flag 'i' is supported. scoped is true for the '(?flags:...)' form.
+func (p *Parser) parseFlags() (mode CaseMode, scoped bool, err error) {
+	start := p.pos
+	mode = p.caseMode
+	on := true
+	seen := false
+	for {
+		c := p.next()
+		switch c {
+		case 'i':
+			if on {
+				mode = CaseInsensitive
+			} else {
+				mode = CaseSensitive
+			}
+			seen = true
+		case '-':
+			if !on {
+				return 0, false, fmt.Errorf("invalid flags at position %d", p.position(start))
+			}
+			on = false
+		case ')', ':':
+			if !seen {
+				return 0, false, fmt.Errorf("missing flag at position %d", p.position(start))
+			}
+			return mode, c == ':', nil
+		case 0:
+			return 0, false, fmt.Errorf("unterminated flags at position %d", p.position(start))
+		default:
+			return 0, false, fmt.Errorf("unsupported flag '%c' at position %d", c, p.position(p.pos-1))
+		}
+	}
+}
+
+// parseLookaround parses the rest of a lookaround assertion after '(?'.
+func (p *Parser) parseLookaround() (*RegexNode, error) {
+	var t NodeType
+	switch {
+	case p.lookingAt("="):
+		t = NodeTypeLookahead
+	case p.lookingAt("!"):
+		t = NodeTypeNegativeLookahead
+	case p.lookingAt("<="):
+		t = NodeTypeLookbehind
 		p.next()
-	case '?':
-		node.Quantifier |= QuantifierOptional
+	case p.lookingAt("<!"):
+		t = NodeTypeNegativeLookbehind
 		p.next()
 	}
+	p.next() // consume '=' or '!'
 
-	return node, nil
+	alt, seq, err := p.parseAlternation(')')
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != ')' {
+		return nil, fmt.Errorf("unmatched '(' at position %d", p.position(p.pos-1))
+	}
+	// consume ')'
+	p.next()
+
+	if alt != nil {
+		seq = []*RegexNode{alt}
+	}
+
+	return NewLookaround(t, seq), nil
+}
+
+// parseGroupName parses a group name delimited by opening and closing, e.g. "<name>".
+// Names must start with a letter or underscore and contain only word characters.
+func (p *Parser) parseGroupName(opening, closing byte) (string, error) {
+	if p.peek() != opening {
+		return "", fmt.Errorf("expected '%c' at position %d", opening, p.position(p.pos))
+	}
+	p.next()
+
+	start := p.pos
+	for !p.eof() && p.peek() != closing {
+		c := p.next()
+		if !WordMatcher.Match(c) || (p.pos-1 == start && isDigit(c)) {
+			return "", fmt.Errorf("invalid character '%c' in group name at position %d", c, p.position(p.pos-1))
+		}
+	}
+	if p.eof() {
+		return "", fmt.Errorf("unterminated group name at position %d", p.position(start))
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("empty group name at position %d", p.position(start))
+	}
+	name := p.pattern[start:p.pos]
+	p.next() // consume closing
+
+	return name, nil
 }
 
-// parseCharClass parses a character class like [abc] or [^abc]. No range parsing required.
+// parseCharClass parses a character class like [abc], [^a-z], [\d\S] or [[:alpha:]_].
+// As in POSIX, a ']' right after '[' or '[^' is a literal.
 func (p *Parser) parseCharClass() (*RegexNode, error) {
 	if p.next() != '[' { // consume '['
-		return nil, fmt.Errorf("expected '[' at position %d", p.pos-1)
+		return nil, fmt.Errorf("expected '[' at position %d", p.position(p.pos-1))
 	}
 
 	negate := false
@@ -237,77 +553,276 @@ func (p *Parser) parseCharClass() (*RegexNode, error) {
 	cg := &CharGroupMatcher{Chars: []byte{}, Ranges: [][2]byte{}, Negate: negate}
 
 	label := ""
+	first := true
 	// collect until ']'
 	for {
 		if p.eof() {
 			return nil, fmt.Errorf("unmatched '[' in character class")
 		}
-		ch := p.next()
-		if ch == ']' {
+		if p.peek() == ']' && !first {
+			p.next()
 			break
 		}
-		if ch == '\\' {
-			if p.eof() {
-				return nil, fmt.Errorf("incomplete escape in character class")
-			}
-			esc := p.next()
-			switch esc {
-			case 'd':
-				cg.Chars = append(cg.Chars, DigitMatcher.Chars...)
-				cg.Ranges = append(cg.Ranges, DigitMatcher.Ranges...)
-				label += DigitMatcher.Label
-			case 'w':
-				cg.Chars = append(cg.Chars, WordMatcher.Chars...)
-				cg.Ranges = append(cg.Ranges, WordMatcher.Ranges...)
-				label += WordMatcher.Label
-			default:
-				cg.Chars = append(cg.Chars, esc)
-				label += string(esc)
-			}
+		first = false
+
+		start := p.pos
+		ch, cls, err := p.parseClassAtom()
+		if err != nil {
+			return nil, err
+		}
+		// Sets are kept nested so negated ones like \S keep their meaning
+		if cls != nil {
+			cg.Classes = append(cg.Classes, cls)
+			label += p.pattern[start:p.pos]
 			continue
 		}
 
-		// Minimal range support: a-b
-		if p.peek() == '-' {
+		// Range: a-b. A '-' before the closing ']' is a literal.
+		if p.peek() == '-' && p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] != ']' {
 			p.next()
-			if p.eof() {
-				return nil, fmt.Errorf("unterminated range in character class")
+			endPos := p.pos
+			end, cls, err := p.parseClassAtom()
+			if err != nil {
+				return nil, err
 			}
-			end := p.next()
-			if end == ']' {
-				cg.Chars = append(cg.Chars, ch, '-')
-				p.pos--
-				label += string(ch) + "-"
-				continue
+			if cls != nil {
+				return nil, fmt.Errorf("invalid range end %q at position %d", p.pattern[endPos:p.pos], p.position(endPos))
 			}
 			if end < ch {
 				ch, end = end, ch
 			}
-			cg.Ranges = append(cg.Ranges, [2]byte{ch, end})
-			label += string(ch) + "-" + string(end)
+			cg.addRange(ch, end)
+			label += p.pattern[start:p.pos]
 			continue
 		}
 
-		cg.Chars = append(cg.Chars, ch)
-		label += string(ch)
+		cg.addRange(ch, ch)
+		label += p.pattern[start:p.pos]
 	}
 	cg.Label = label
 
-	// optional quantifier after class
-	node := NewCharGroupMatch(cg)
-	switch p.peek() {
-	case '+':
-		node.Quantifier |= QuantifierPlus
-		p.next()
-	case '*':
-		node.Quantifier |= QuantifierAsterisk
+	return NewCharGroupMatch(p.set(cg)), nil
+}
+
+// parseClassAtom parses one element of a character class. It returns either a single
+// character or, for shorthands like \d and POSIX classes like [:alpha:], a set.
+func (p *Parser) parseClassAtom() (rune, *CharGroupMatcher, error) {
+	start := p.pos
+	if r, size := p.peekRune(); size > 1 {
+		p.pos += size
+		return r, nil, nil
+	}
+	ch := p.next()
+
+	switch {
+	case ch == '\\':
+		if p.eof() {
+			return 0, nil, fmt.Errorf("incomplete escape in character class")
+		}
+		esc := p.next()
+		if esc == 'p' || esc == 'P' {
+			m, err := p.parseUnicodeClass(esc)
+			return 0, m, err
+		}
+		if m := shorthandMatcher(esc); m != nil {
+			return 0, m, nil
+		}
+		b, err := p.parseByteEscape(esc)
+		return rune(b), nil, err
+	case ch == '[' && p.peek() == ':':
+		name, err := p.parseBracketItem(':')
+		if err != nil {
+			return 0, nil, err
+		}
+		m, ok := posixClasses[name]
+		if !ok {
+			return 0, nil, fmt.Errorf("unknown POSIX class %q at position %d", name, p.position(start))
+		}
+		return 0, m, nil
+	case ch == '[' && (p.peek() == '=' || p.peek() == '.'):
+		// Equivalence classes [=a=] and collating symbols [.-.]; in ASCII both
+		// stand for the single character they name
+		name, err := p.parseBracketItem(p.peek())
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(name) != 1 {
+			return 0, nil, fmt.Errorf("unknown collating element %q at position %d", name, p.position(start))
+		}
+		return rune(name[0]), nil, nil
+	}
+
+	return rune(ch), nil, nil
+}
+
+// parseUnicodeClass parses a Unicode property class after '\p' or '\P': a one-letter
+// category like \pL or a braced category or script name like \p{Lu} or \p{Greek}.
+// Classes of runes need UTF-8 mode, as bytes would match them one at a time.
+func (p *Parser) parseUnicodeClass(esc byte) (*CharGroupMatcher, error) {
+	start := p.pos - 2
+	if !p.unicode {
+		return nil, fmt.Errorf("class '\\%c' needs UTF-8 mode at position %d", esc, p.position(start))
+	}
+
+	var name string
+	if p.peek() == '{' {
 		p.next()
-	case '?':
-		node.Quantifier |= QuantifierOptional
+		nameStart := p.pos
+		for !p.eof() && p.peek() != '}' {
+			p.next()
+		}
+		if p.eof() {
+			return nil, fmt.Errorf("unterminated Unicode class at position %d", p.position(start))
+		}
+		name = p.pattern[nameStart:p.pos]
+		p.next() // consume '}'
+	} else {
+		if p.eof() {
+			return nil, fmt.Errorf("missing Unicode class name at position %d", p.position(start))
+		}
+		name = string(p.next())
+	}
+
+	negate := esc == 'P'
+	if len(name) > 1 && name[0] == '^' {
+		// \p{^Greek} is the same as \P{Greek}
+		name = name[1:]
+		negate = !negate
+	}
+
+	table, ok := unicode.Categories[name]
+	if !ok {
+		table, ok = unicode.Scripts[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown Unicode class %q at position %d", name, p.position(start))
+	}
+
+	return p.set(&CharGroupMatcher{
+		Tables: []*unicode.RangeTable{table},
+		Negate: negate,
+		Label:  p.pattern[start:p.pos],
+	}), nil
+}
+
+// position maps pos in the pattern being parsed back to the pattern as given, for errors.
+func (p *Parser) position(pos int) int {
+	if p.offsets == nil {
+		return pos
+	}
+
+	return p.offsets[min(max(pos, 0), len(p.offsets)-1)]
+}
+
+// set returns m, or in UTF-8 mode a copy of m that matches whole runes.
+func (p *Parser) set(m *CharGroupMatcher) *CharGroupMatcher {
+	if !p.unicode || m.UTF8 {
+		return m
+	}
+
+	utf := *m
+	utf.UTF8 = true
+
+	return &utf
+}
+
+// peekRune decodes the next character in UTF-8 mode. size is 0 outside of UTF-8 mode
+// and 1 for ASCII or an invalid sequence, which is then read as a single byte.
+func (p *Parser) peekRune() (rune, int) {
+	if !p.unicode || p.eof() {
+		return 0, 0
+	}
+
+	r, size := utf8.DecodeRuneInString(p.pattern[p.pos:])
+	if r == utf8.RuneError {
+		return r, 1
+	}
+
+	return r, size
+}
+
+// parseBracketItem parses the name inside '[:name:]', '[=c=]' or '[.c.]' after the
+// opening '[' has been consumed.
+func (p *Parser) parseBracketItem(delim byte) (string, error) {
+	start := p.pos - 1
+	p.next() // consume delim
+
+	nameStart := p.pos
+	for !p.eof() {
+		if p.peek() == delim && p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] == ']' {
+			name := p.pattern[nameStart:p.pos]
+			p.pos += 2 // consume delim and ']'
+			return name, nil
+		}
 		p.next()
 	}
 
-	return node, nil
+	return "", fmt.Errorf("unterminated '[%c' in character class at position %d", delim, p.position(start))
+}
+
+// shorthandMatcher returns the predefined class for a shorthand escape like \d or \S,
+// or nil if esc is not a shorthand.
+func shorthandMatcher(esc byte) *CharGroupMatcher {
+	switch esc {
+	case 'd':
+		return DigitMatcher
+	case 'D':
+		return NonDigitMatcher
+	case 'w':
+		return WordMatcher
+	case 'W':
+		return NonWordMatcher
+	case 's':
+		return SpaceMatcher
+	case 'S':
+		return NonSpaceMatcher
+	case 'h':
+		return HorizontalSpaceMatcher
+	case 'H':
+		return NonHorizontalSpaceMatcher
+	case 'v':
+		return VerticalSpaceMatcher
+	case 'V':
+		return NonVerticalSpaceMatcher
+	}
+
+	return nil
+}
+
+// parseByteEscape returns the byte for an escape whose letter esc has been consumed:
+// control escapes \t, \n, \r, \f, hex \xHH and octal \0oo. Any other character
+// escapes itself.
+func (p *Parser) parseByteEscape(esc byte) (byte, error) {
+	switch esc {
+	case 't':
+		return '\t', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 'f':
+		return '\f', nil
+	case 'x':
+		start := p.pos
+		var b byte
+		for range 2 {
+			d, ok := hexValue(p.peek())
+			if !ok {
+				return 0, fmt.Errorf("invalid hex escape at position %d, expected \\xHH", p.position(start-2))
+			}
+			p.next()
+			b = b<<4 | d
+		}
+		return b, nil
+	case '0':
+		var b byte
+		for i := 0; i < 2 && p.peek() >= '0' && p.peek() <= '7'; i++ {
+			b = b<<3 | (p.next() - '0')
+		}
+		return b, nil
+	}
+
+	return esc, nil
 }
 
 // helpers
@@ -328,3 +843,22 @@ func (p *Parser) next() byte {
 }
 
 func (p *Parser) eof() bool { return p.pos >= len(p.pattern) }
+
+// lookingAt reports whether the unconsumed pattern starts with prefix.
+func (p *Parser) lookingAt(prefix string) bool {
+	return len(p.pattern)-p.pos >= len(prefix) && p.pattern[p.pos:p.pos+len(prefix)] == prefix
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
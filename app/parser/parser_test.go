@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -14,6 +16,10 @@ func nodesEqual(a, b *RegexNode) bool {
 		return false
 	}
 
+	if a.Min != b.Min || a.Max != b.Max || a.Case != b.Case {
+		return false
+	}
+
 	// Compare Values for match nodes
 	switch av := a.Value.(type) {
 	case *LiteralMatcher:
@@ -23,7 +29,7 @@ func nodesEqual(a, b *RegexNode) bool {
 		}
 	case *CharGroupMatcher:
 		bv, ok := b.Value.(*CharGroupMatcher)
-		if !ok {
+		if !ok || av.UTF8 != bv.UTF8 || !slices.Equal(av.RuneRanges, bv.RuneRanges) {
 			return false
 		}
 		// If labels are set (e.g., \d, \w), compare labels first
@@ -78,20 +84,28 @@ func TestParser_Parse_Basic(t *testing.T) {
 	}{
 		{
 			name:    "backreference \\1",
-			pattern: "\\1",
+			pattern: "(a)\\1",
 			want: func() *RegexNode {
+				group := NewGroup([]*RegexNode{NewLiteralMatch('a')})
+				group.Capturing = true
 				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					group,
 					NewBackreference("1"),
 				}}
 			},
 		},
 		{
 			name:    "backreference multi-digit \\12",
-			pattern: "\\12",
+			pattern: strings.Repeat("(a)", 12) + "\\12",
 			want: func() *RegexNode {
-				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
-					NewBackreference("12"),
-				}}
+				var children []*RegexNode
+				for range 12 {
+					group := NewGroup([]*RegexNode{NewLiteralMatch('a')})
+					group.Capturing = true
+					children = append(children, group)
+				}
+				children = append(children, NewBackreference("12"))
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: children}
 			},
 		},
 		{
@@ -121,6 +135,33 @@ func TestParser_Parse_Basic(t *testing.T) {
 				}}
 			},
 		},
+		{
+			name:    "shorthands \\s\\S\\D\\W\\h\\v",
+			pattern: "\\s\\S\\D\\W\\h\\v",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(SpaceMatcher),
+					NewCharGroupMatch(NonSpaceMatcher),
+					NewCharGroupMatch(NonDigitMatcher),
+					NewCharGroupMatch(NonWordMatcher),
+					NewCharGroupMatch(HorizontalSpaceMatcher),
+					NewCharGroupMatch(VerticalSpaceMatcher),
+				}}
+			},
+		},
+		{
+			name:    "byte escapes \\t\\n\\x41\\012\\0",
+			pattern: "\\t\\n\\x41\\012\\0",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewLiteralMatch('\t'),
+					NewLiteralMatch('\n'),
+					NewLiteralMatch('A'),
+					NewLiteralMatch('\n'),
+					NewLiteralMatch(0),
+				}}
+			},
+		},
 		{
 			name:    "wildcard dot",
 			pattern: ".",
@@ -160,6 +201,26 @@ func TestParser_Parse_Basic(t *testing.T) {
 				}}
 			},
 		},
+		{
+			name:    "POSIX class [[:alpha:]_]",
+			pattern: "[[:alpha:]_]",
+			want: func() *RegexNode {
+				cg := &CharGroupMatcher{Chars: []byte{'_'}, Classes: []*CharGroupMatcher{posixClasses["alpha"]}, Label: "[:alpha:]_"}
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(cg),
+				}}
+			},
+		},
+		{
+			name:    "leading ] is literal []a]",
+			pattern: "[]a]",
+			want: func() *RegexNode {
+				cg := &CharGroupMatcher{Chars: []byte{']', 'a'}, Label: "]a"}
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(cg),
+				}}
+			},
+		},
 		{
 			name:    "range character class [a-c]",
 			pattern: "[a-c]",
@@ -182,6 +243,18 @@ func TestParser_Parse_Basic(t *testing.T) {
 				}}
 			},
 		},
+		{
+			name:    "word boundaries \\bab\\B",
+			pattern: "\\bab\\B",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewWordBoundary(),
+					NewLiteralMatch('a'),
+					NewLiteralMatch('b'),
+					NewNonWordBoundary(),
+				}}
+			},
+		},
 		{
 			name:    "quantifier plus a+",
 			pattern: "a+",
@@ -200,6 +273,73 @@ func TestParser_Parse_Basic(t *testing.T) {
 				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{n}}
 			},
 		},
+		{
+			name:    "lazy asterisk a*?",
+			pattern: "a*?",
+			want: func() *RegexNode {
+				n := NewLiteralMatch('a')
+				n.Quantifier = QuantifierAsterisk | QuantifierLazy
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{n}}
+			},
+		},
+		{
+			name:    "lazy optional a??",
+			pattern: "a??",
+			want: func() *RegexNode {
+				n := NewLiteralMatch('a')
+				n.Quantifier = QuantifierOptional | QuantifierLazy
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{n}}
+			},
+		},
+		{
+			name:    "lazy range a{2,3}?",
+			pattern: "a{2,3}?",
+			want: func() *RegexNode {
+				n := NewLiteralMatch('a').WithRange(2, 3)
+				n.Quantifier |= QuantifierLazy
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{n}}
+			},
+		},
+		{
+			name:    "exact repetition a{3}",
+			pattern: "a{3}",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewLiteralMatch('a').WithRange(3, 3),
+				}}
+			},
+		},
+		{
+			name:    "open repetition \\d{2,}",
+			pattern: "\\d{2,}",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(DigitMatcher).WithRange(2, -1),
+				}}
+			},
+		},
+		{
+			name:    "bounded repetition on class [ab]{1,3}",
+			pattern: "[ab]{1,3}",
+			want: func() *RegexNode {
+				cg := &CharGroupMatcher{Chars: []byte{'a', 'b'}, Label: "ab"}
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(cg).WithRange(1, 3),
+				}}
+			},
+		},
+		{
+			name:    "brace without digits is literal a{x}",
+			pattern: "a{x}",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewLiteralMatch('a'),
+					NewLiteralMatch('{'),
+					NewLiteralMatch('x'),
+					NewLiteralMatch('}'),
+				}}
+			},
+		},
 		{
 			name:    "simple capturing group (ab)",
 			pattern: "(ab)",
@@ -219,6 +359,113 @@ func TestParser_Parse_Basic(t *testing.T) {
 				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{g}}
 			},
 		},
+		{
+			name:    "capturing group with range (ab){0,2}",
+			pattern: "(ab){0,2}",
+			want: func() *RegexNode {
+				g := NewGroup([]*RegexNode{NewLiteralMatch('a'), NewLiteralMatch('b')})
+				g.Capturing = true
+				g.WithRange(0, 2)
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{g}}
+			},
+		},
+		{
+			name:    "non-capturing group (?:ab)+",
+			pattern: "(?:ab)+",
+			want: func() *RegexNode {
+				g := NewGroup([]*RegexNode{NewLiteralMatch('a'), NewLiteralMatch('b')})
+				g.Quantifier = QuantifierPlus
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{g}}
+			},
+		},
+		{
+			name:    "non-capturing alternation (?:a|b)",
+			pattern: "(?:a|b)",
+			want: func() *RegexNode {
+				alt := NewAlternation([]*RegexNode{NewLiteralMatch('a'), NewLiteralMatch('b')})
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{alt}}
+			},
+		},
+		{
+			name:    "named group (?P<year>\\d)",
+			pattern: "(?P<year>\\d)",
+			want: func() *RegexNode {
+				g := NewGroup([]*RegexNode{NewCharGroupMatch(DigitMatcher)})
+				g.Capturing = true
+				g.GroupName = "year"
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{g}}
+			},
+		},
+		{
+			name:    "named group and backreference (?<w>a)\\k<w>",
+			pattern: "(?<w>a)\\k<w>",
+			want: func() *RegexNode {
+				g := NewGroup([]*RegexNode{NewLiteralMatch('a')})
+				g.Capturing = true
+				g.GroupName = "w"
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{g, NewBackreference("w")}}
+			},
+		},
+		{
+			name:    "lookahead a(?=b)",
+			pattern: "a(?=b)",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewLiteralMatch('a'),
+					NewLookaround(NodeTypeLookahead, []*RegexNode{NewLiteralMatch('b')}),
+				}}
+			},
+		},
+		{
+			name:    "negative lookahead with alternation (?!a|b)",
+			pattern: "(?!a|b)",
+			want: func() *RegexNode {
+				alt := NewAlternation([]*RegexNode{NewLiteralMatch('a'), NewLiteralMatch('b')})
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewLookaround(NodeTypeNegativeLookahead, []*RegexNode{alt}),
+				}}
+			},
+		},
+		{
+			name:    "lookbehinds (?<=a)(?<!b)",
+			pattern: "(?<=a)(?<!b)",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewLookaround(NodeTypeLookbehind, []*RegexNode{NewLiteralMatch('a')}),
+					NewLookaround(NodeTypeNegativeLookbehind, []*RegexNode{NewLiteralMatch('b')}),
+				}}
+			},
+		},
+		{
+			name:    "inline flags a(?i)b(?-i:c)d",
+			pattern: "a(?i)b(?-i:c)d",
+			want: func() *RegexNode {
+				b := NewLiteralMatch('b')
+				b.Case = CaseInsensitive
+				c := NewLiteralMatch('c')
+				c.Case = CaseSensitive
+				d := NewLiteralMatch('d')
+				d.Case = CaseInsensitive
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewLiteralMatch('a'),
+					NewGroup(nil),
+					b,
+					NewGroup([]*RegexNode{c}),
+					d,
+				}}
+			},
+		},
+		{
+			name:    "inline flag ends with its group (a(?i)b)c",
+			pattern: "(a(?i)b)c",
+			want: func() *RegexNode {
+				b := NewLiteralMatch('b')
+				b.Case = CaseInsensitive
+				g := NewGroup([]*RegexNode{NewLiteralMatch('a'), NewGroup(nil), b})
+				g.Capturing = true
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{g, NewLiteralMatch('c')}}
+			},
+		},
 		{
 			name:    "simple alternation a|b|c",
 			pattern: "a|b|c",
@@ -256,11 +503,145 @@ func TestParser_Parse_Basic(t *testing.T) {
 	}
 }
 
+func TestParser_Parse_Unicode(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    func() *RegexNode
+	}{
+		{
+			name:    "multibyte literal is one rune é+",
+			pattern: "é+",
+			want: func() *RegexNode {
+				n := NewCharGroupMatch(&CharGroupMatcher{RuneRanges: [][2]rune{{'é', 'é'}}, UTF8: true, Label: "é"})
+				n.Quantifier = QuantifierPlus
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{n}}
+			},
+		},
+		{
+			name:    "wildcard and shorthands match runes",
+			pattern: ".\\S",
+			want: func() *RegexNode {
+				dot := *WildcardMatcher
+				dot.UTF8 = true
+				nonSpace := *NonSpaceMatcher
+				nonSpace.UTF8 = true
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(&dot),
+					NewCharGroupMatch(&nonSpace),
+				}}
+			},
+		},
+		{
+			name:    "class with rune range [a-ž]",
+			pattern: "[a-ž]",
+			want: func() *RegexNode {
+				cg := &CharGroupMatcher{Ranges: [][2]byte{{'a', 0xFF}}, RuneRanges: [][2]rune{{0x100, 'ž'}}, UTF8: true, Label: "a-ž"}
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(cg),
+				}}
+			},
+		},
+		{
+			name:    "property classes \\p{Greek}\\PL",
+			pattern: "\\p{Greek}\\PL",
+			want: func() *RegexNode {
+				return &RegexNode{Type: NodeTypeGroup, Capturing: true, Children: []*RegexNode{
+					NewCharGroupMatch(&CharGroupMatcher{UTF8: true, Label: "\\p{Greek}"}),
+					NewCharGroupMatch(&CharGroupMatcher{UTF8: true, Negate: true, Label: "\\PL"}),
+				}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.pattern).WithUnicode().Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			expected := tt.want()
+			if !nodesEqual(got, expected) {
+				t.Errorf("Parse() AST mismatch for pattern %q\n got: %#v\nwant: %#v", tt.pattern, got, expected)
+			}
+		})
+	}
+}
+
+func TestCharGroupMatcher_MatchRune(t *testing.T) {
+	greek, err := New("\\p{Greek}").WithUnicode().Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	m := greek.Children[0].Value.(*CharGroupMatcher)
+	if !m.MatchRune('λ') || m.MatchRune('a') {
+		t.Errorf("\\p{Greek} MatchRune mismatch")
+	}
+
+	folded := WithFoldCase(&CharGroupMatcher{RuneRanges: [][2]rune{{'ä', 'ä'}}, UTF8: true}).(*CharGroupMatcher)
+	if !folded.MatchRune('Ä') {
+		t.Errorf("folded MatchRune('Ä') = false, want true")
+	}
+}
+
+func TestParser_Parse_UnicodeClassNeedsUTF8(t *testing.T) {
+	_, err := New("ab\\pL").Parse()
+	if want := "class '\\p' needs UTF-8 mode at position 2"; err == nil || err.Error() != want {
+		t.Fatalf("Parse() error = %v, want %q", err, want)
+	}
+
+	if _, err := New("ab\\pL").WithUnicode().Parse(); err != nil {
+		t.Fatalf("Parse() in UTF-8 mode error = %v", err)
+	}
+}
+
+func TestParser_Parse_RepetitionExpansion(t *testing.T) {
+	if _, err := New("(ab{100}){100}").Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	_, err := New("x(a{1000}){1000}").Parse()
+	if err == nil || err.Error() != "repetition too large at position 10" {
+		t.Fatalf("Parse() error = %v, want repetition too large at position 10", err)
+	}
+}
+
+func TestParser_Parse_MissingBackreferenceGroup(t *testing.T) {
+	_, err := New("(?<a>x)\\k<b>").Parse()
+	if err == nil || err.Error() != `backreference to missing group "b" at position 7` {
+		t.Fatalf("Parse() error = %v, want a missing group error at position 7", err)
+	}
+
+	_, err = New("(a)\\2").Parse()
+	if err == nil || err.Error() != "backreference to missing group 2 at position 3" {
+		t.Fatalf("Parse() error = %v, want a missing group error at position 3", err)
+	}
+}
+
 func TestParser_Parse_Errors(t *testing.T) {
 	tests := []string{
-		"[abc", // unmatched [
-		"(ab",  // unmatched (
-		"\\",   // dangling escape
+		"[abc",                    // unmatched [
+		"(ab",                     // unmatched (
+		"\\",                      // dangling escape
+		"a{3,1}",                  // reversed range
+		"a{3",                     // unterminated brace
+		"a{3,x}",                  // garbage in brace
+		"a{1001}",                 // count too large
+		"(a{1000}){1000}",         // nested counts multiply past the expansion limit
+		"((a{1000}){1000}){1000}", // nested counts multiply past the expansion limit
+		"(?P<1a>x)",               // name starts with a digit
+		"(?<a>x)(?<a>y)",          // duplicate name
+		"(?<>x)",                  // empty name
+		"(?x)",                    // unknown group syntax
+		"\\k<name",                // unterminated backreference name
+		"\\1(a)",                  // backreference before its group
+		"(a)\\2",                  // backreference to a missing numbered group
+		"\\101",                   // backreference, not an octal escape, to a missing group
+		"(?<a>x)\\k<b>",           // backreference to a missing named group
+		"\\kname",                 // backreference name without brackets
+		"\\p{Greek}",              // Unicode class outside UTF-8 mode
+		"[^\\PL]",                 // Unicode class in brackets outside UTF-8 mode
 	}
 
 	for _, pattern := range tests {
@@ -1,6 +1,9 @@
 package parser
 
-import "slices"
+import (
+	"slices"
+	"unicode"
+)
 
 type NodeType int
 
@@ -11,6 +14,14 @@ const (
 	NodeTypeAlternation
 	NodeTypeGroup
 	NodeTypeBackreference
+	NodeTypeLookahead          // (?=...)
+	NodeTypeNegativeLookahead  // (?!...)
+	NodeTypeLookbehind         // (?<=...)
+	NodeTypeNegativeLookbehind // (?<!...)
+	NodeTypeWordBoundary       // \b
+	NodeTypeNonWordBoundary    // \B
+	NodeTypeNoWordBefore       // No word character before, for -w
+	NodeTypeNoWordAfter        // No word character after, for -w
 )
 
 type RegexNode struct {
@@ -20,14 +31,35 @@ type RegexNode struct {
 	Quantifier Quantifier
 	Capturing  bool
 	GroupName  string
+	Min        int      // For QuantifierRange, minimum repetitions
+	Max        int      // For QuantifierRange, maximum repetitions; -1 means unbounded
+	Case       CaseMode // For match nodes and backreferences, set by inline (?i) flags
 }
 
+// CaseMode records an inline case-sensitivity flag in effect for a node.
+type CaseMode int
+
+const (
+	CaseDefault     CaseMode = iota // Use the compile options
+	CaseInsensitive                 // (?i)
+	CaseSensitive                   // (?-i)
+)
+
 func (n *RegexNode) WithQuantifier(q Quantifier) *RegexNode {
 	n.Quantifier = q
 
 	return n
 }
 
+// WithRange sets a bounded repetition {min,max}; max -1 means unbounded.
+func (n *RegexNode) WithRange(min, max int) *RegexNode {
+	n.Quantifier = n.Quantifier&QuantifierLazy | QuantifierRange
+	n.Min = min
+	n.Max = max
+
+	return n
+}
+
 func NewLiteralMatch(value byte) *RegexNode {
 	return &RegexNode{
 		Type:  NodeTypeMatch,
@@ -54,6 +86,34 @@ func NewDollarAnchor() *RegexNode {
 	}
 }
 
+func NewWordBoundary() *RegexNode {
+	return &RegexNode{
+		Type: NodeTypeWordBoundary,
+	}
+}
+
+func NewNonWordBoundary() *RegexNode {
+	return &RegexNode{
+		Type: NodeTypeNonWordBoundary,
+	}
+}
+
+// NewNoWordBefore creates an assertion that the previous character, if any, is not a word
+// character. Unlike \b it also holds before a non-word character.
+func NewNoWordBefore() *RegexNode {
+	return &RegexNode{
+		Type: NodeTypeNoWordBefore,
+	}
+}
+
+// NewNoWordAfter creates an assertion that the next character, if any, is not a word
+// character.
+func NewNoWordAfter() *RegexNode {
+	return &RegexNode{
+		Type: NodeTypeNoWordAfter,
+	}
+}
+
 func NewGroup(children []*RegexNode) *RegexNode {
 	return &RegexNode{
 		Type:     NodeTypeGroup,
@@ -75,6 +135,14 @@ func NewBackreference(groupName string) *RegexNode {
 	}
 }
 
+// NewLookaround creates a zero-width assertion node of the given type matching children.
+func NewLookaround(t NodeType, children []*RegexNode) *RegexNode {
+	return &RegexNode{
+		Type:     t,
+		Children: children,
+	}
+}
+
 type Quantifier int
 
 const (
@@ -82,6 +150,8 @@ const (
 	QuantifierAsterisk
 	QuantifierPlus
 	QuantifierOptional
+	QuantifierRange
+	QuantifierLazy // Prefer fewer repetitions, e.g. *?, +?, ??, {n,m}?
 )
 
 func (q Quantifier) None() bool {
@@ -100,17 +170,26 @@ func (q Quantifier) Asterisk() bool {
 	return q&QuantifierAsterisk != 0
 }
 
+func (q Quantifier) Range() bool {
+	return q&QuantifierRange != 0
+}
+
+func (q Quantifier) Lazy() bool {
+	return q&QuantifierLazy != 0
+}
+
 type Matcher interface {
 	Match(c byte) bool
 	String() string
 }
 
 type LiteralMatcher struct {
-	Char byte
+	Char     byte
+	FoldCase bool
 }
 
 func (m *LiteralMatcher) Match(c byte) bool {
-	return m.Char == c
+	return m.Char == c || (m.FoldCase && m.Char == swapCase(c))
 }
 
 func (m *LiteralMatcher) String() string {
@@ -118,19 +197,37 @@ func (m *LiteralMatcher) String() string {
 }
 
 type CharGroupMatcher struct {
-	Chars  []byte
-	Ranges [][2]byte
-	Negate bool
-	Label  string
+	Chars      []byte
+	Ranges     [][2]byte
+	RuneRanges [][2]rune             // Characters above 0xFF
+	Tables     []*unicode.RangeTable // Unicode properties such as \p{Greek}
+	Classes    []*CharGroupMatcher   // Nested sets such as \S inside [...], matched with their own negation
+	Negate     bool
+	FoldCase   bool // Also match the other case of a letter, before negation
+	UTF8       bool // Match a whole UTF-8 encoded rune instead of a single byte
+	Label      string
 }
 
 func (m *CharGroupMatcher) Match(c byte) bool {
-	found := slices.Contains(m.Chars, c)
+	found := m.contains(rune(c))
+	if !found && m.FoldCase {
+		found = m.contains(rune(swapCase(c)))
+	}
 
-	if !found {
-		found = slices.ContainsFunc(m.Ranges, func(r [2]byte) bool {
-			return c >= r[0] && c <= r[1]
-		})
+	if m.Negate {
+		return !found
+	}
+
+	return found
+}
+
+// MatchRune matches a decoded rune. Case folding follows Unicode simple folding.
+func (m *CharGroupMatcher) MatchRune(r rune) bool {
+	found := m.contains(r)
+	if !found && m.FoldCase {
+		for f := unicode.SimpleFold(r); f != r && !found; f = unicode.SimpleFold(f) {
+			found = m.contains(f)
+		}
 	}
 
 	if m.Negate {
@@ -140,10 +237,85 @@ func (m *CharGroupMatcher) Match(c byte) bool {
 	return found
 }
 
+func (m *CharGroupMatcher) contains(r rune) bool {
+	if r >= 0 && r <= 0xFF {
+		c := byte(r)
+		if slices.Contains(m.Chars, c) || slices.ContainsFunc(m.Ranges, func(rng [2]byte) bool {
+			return c >= rng[0] && c <= rng[1]
+		}) {
+			return true
+		}
+	}
+
+	if slices.ContainsFunc(m.RuneRanges, func(rng [2]rune) bool {
+		return r >= rng[0] && r <= rng[1]
+	}) {
+		return true
+	}
+
+	if slices.ContainsFunc(m.Tables, func(t *unicode.RangeTable) bool {
+		return unicode.Is(t, r)
+	}) {
+		return true
+	}
+
+	return slices.ContainsFunc(m.Classes, func(cls *CharGroupMatcher) bool {
+		return cls.MatchRune(r)
+	})
+}
+
+// addRange adds the characters lo..hi, keeping those up to 0xFF in the byte sets.
+func (m *CharGroupMatcher) addRange(lo, hi rune) {
+	if lo <= 0xFF {
+		top := min(hi, 0xFF)
+		if lo == top {
+			m.Chars = append(m.Chars, byte(lo))
+		} else {
+			m.Ranges = append(m.Ranges, [2]byte{byte(lo), byte(top)})
+		}
+		lo = 0x100
+	}
+
+	if lo <= hi {
+		m.RuneRanges = append(m.RuneRanges, [2]rune{lo, hi})
+	}
+}
+
 func (m *CharGroupMatcher) String() string {
 	return m.Label
 }
 
+// WithFoldCase returns a copy of m that also matches the other case of ASCII letters.
+func WithFoldCase(m Matcher) Matcher {
+	switch m := m.(type) {
+	case *LiteralMatcher:
+		folded := *m
+		folded.FoldCase = true
+		return &folded
+	case *CharGroupMatcher:
+		folded := *m
+		folded.FoldCase = true
+		return &folded
+	}
+	return m
+}
+
+// swapCase returns the other case of an ASCII letter, or c unchanged.
+func swapCase(c byte) byte {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return c - 'a' + 'A'
+	case c >= 'A' && c <= 'Z':
+		return c - 'A' + 'a'
+	}
+	return c
+}
+
+// EqualFold reports whether a and b are the same byte ignoring ASCII case.
+func EqualFold(a, b byte) bool {
+	return a == b || a == swapCase(b)
+}
+
 var (
 	// \d
 	DigitMatcher = &CharGroupMatcher{
@@ -153,6 +325,8 @@ var (
 		},
 		Label: `\d`,
 	}
+	// \D
+	NonDigitMatcher = negated(DigitMatcher, `\D`)
 	// \w
 	WordMatcher = &CharGroupMatcher{
 		Chars: []byte{'_'},
@@ -163,6 +337,29 @@ var (
 		},
 		Label: `\w`,
 	}
+	// \W
+	NonWordMatcher = negated(WordMatcher, `\W`)
+	// \s
+	SpaceMatcher = &CharGroupMatcher{
+		Chars: []byte{' ', '\t', '\n', '\r', '\f', '\v'},
+		Label: `\s`,
+	}
+	// \S
+	NonSpaceMatcher = negated(SpaceMatcher, `\S`)
+	// \h
+	HorizontalSpaceMatcher = &CharGroupMatcher{
+		Chars: []byte{' ', '\t'},
+		Label: `\h`,
+	}
+	// \H
+	NonHorizontalSpaceMatcher = negated(HorizontalSpaceMatcher, `\H`)
+	// \v
+	VerticalSpaceMatcher = &CharGroupMatcher{
+		Chars: []byte{'\n', '\v', '\f', '\r'},
+		Label: `\v`,
+	}
+	// \V
+	NonVerticalSpaceMatcher = negated(VerticalSpaceMatcher, `\V`)
 
 	// .
 	WildcardMatcher = &CharGroupMatcher{
@@ -171,3 +368,29 @@ var (
 		Negate: true,
 	}
 )
+
+// posixClasses holds the POSIX named classes usable as [[:name:]] in a character class.
+var posixClasses = map[string]*CharGroupMatcher{
+	"alpha":  {Ranges: [][2]byte{{'a', 'z'}, {'A', 'Z'}}, Label: "[:alpha:]"},
+	"digit":  {Ranges: [][2]byte{{'0', '9'}}, Label: "[:digit:]"},
+	"alnum":  {Ranges: [][2]byte{{'a', 'z'}, {'A', 'Z'}, {'0', '9'}}, Label: "[:alnum:]"},
+	"upper":  {Ranges: [][2]byte{{'A', 'Z'}}, Label: "[:upper:]"},
+	"lower":  {Ranges: [][2]byte{{'a', 'z'}}, Label: "[:lower:]"},
+	"space":  {Chars: []byte{' ', '\t', '\n', '\r', '\f', '\v'}, Label: "[:space:]"},
+	"blank":  {Chars: []byte{' ', '\t'}, Label: "[:blank:]"},
+	"punct":  {Ranges: [][2]byte{{'!', '/'}, {':', '@'}, {'[', '`'}, {'{', '~'}}, Label: "[:punct:]"},
+	"print":  {Ranges: [][2]byte{{' ', '~'}}, Label: "[:print:]"},
+	"graph":  {Ranges: [][2]byte{{'!', '~'}}, Label: "[:graph:]"},
+	"cntrl":  {Chars: []byte{0x7f}, Ranges: [][2]byte{{0x00, 0x1f}}, Label: "[:cntrl:]"},
+	"xdigit": {Ranges: [][2]byte{{'0', '9'}, {'A', 'F'}, {'a', 'f'}}, Label: "[:xdigit:]"},
+}
+
+// negated returns a matcher for the complement of m.
+func negated(m *CharGroupMatcher, label string) *CharGroupMatcher {
+	return &CharGroupMatcher{
+		Chars:  m.Chars,
+		Ranges: m.Ranges,
+		Negate: !m.Negate,
+		Label:  label,
+	}
+}
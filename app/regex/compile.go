@@ -2,29 +2,115 @@ package regex
 
 import (
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/codecrafters-io/grep-starter-go/app/parser"
 )
 
+// Options controls how a pattern is compiled.
+type Options struct {
+	// CaseInsensitive makes letters match either case unless the pattern overrides it
+	// with an inline (?-i) flag.
+	CaseInsensitive bool
+	// UTF8 marks a pattern parsed in UTF-8 mode, so searches move over whole runes.
+	UTF8 bool
+}
+
 func Compile(root *parser.RegexNode) (*CompiledRegex, error) {
-	return compile(root, new(int))
+	return CompileWithOptions(root, Options{})
+}
+
+func CompileWithOptions(root *parser.RegexNode, opts Options) (*CompiledRegex, error) {
+	c := &compiler{opts: opts}
+	re, err := c.compile(root)
+	if err != nil {
+		return nil, err
+	}
+	re.literals = c.literals(root)
+	re.subexpNames = c.names
+	re.utf8 = opts.UTF8
+
+	return re, nil
+}
+
+// CompileMulti compiles several patterns into one regex that matches wherever any of them
+// does, preferring earlier patterns. The last state of each pattern accepts its index, so
+// matchers can report which pattern matched. Groups are numbered per pattern, so
+// backreferences keep referring to groups of their own pattern, and a group number takes
+// the name of the first pattern naming it.
+func CompileMulti(roots []*parser.RegexNode, opts Options) (*CompiledRegex, error) {
+	start, end := NewState(), NewState()
+	re := &CompiledRegex{initialState: start, endingState: end}
+
+	var literals literalInfo
+	for i, root := range roots {
+		c := &compiler{opts: opts}
+		sub, err := c.compile(root)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %w", i+1, err)
+		}
+
+		start.AddTransition(sub.initialState, EpsilonTransitioner{})
+		sub.endingState.Accepts = append(sub.endingState.Accepts, i)
+		sub.endingState.AddTransition(end, EpsilonTransitioner{})
+
+		for n, name := range c.names {
+			if n == len(re.subexpNames) {
+				re.subexpNames = append(re.subexpNames, name)
+			} else if re.subexpNames[n] == "" {
+				re.subexpNames[n] = name
+			}
+		}
+
+		if i == 0 {
+			literals = c.literalInfo(root)
+		} else {
+			literals = altLiterals(literals, c.literalInfo(root))
+		}
+	}
+	re.literals = Literals{Prefix: literals.prefix, Required: literals.inner}
+	re.utf8 = opts.UTF8
+
+	return re, nil
+}
+
+// compiler holds the state shared while compiling one pattern.
+type compiler struct {
+	opts   Options
+	grpNum int      // Next capture group number
+	names  []string // Name of each numbered group, "" if unnamed
 }
 
-func compile(node *parser.RegexNode, grpNum *int) (*CompiledRegex, error) {
+func (c *compiler) compile(node *parser.RegexNode) (*CompiledRegex, error) {
+	if node.Quantifier.Range() {
+		return c.compileRange(node)
+	}
+
 	var re *CompiledRegex
 	switch node.Type {
 	case parser.NodeTypeMatch:
-		re = singleMatchRegex(node.Value)
+		re = singleMatchRegex(c.matcher(node))
 	case parser.NodeTypeCaretAnchor:
 		re = singleTransitionRegex(StartOfStringTransitioner{})
 	case parser.NodeTypeDollorAnchor:
 		re = singleTransitionRegex(EndOfStringTransitioner{})
+	case parser.NodeTypeWordBoundary:
+		re = singleTransitionRegex(WordBoundaryTransitioner{})
+	case parser.NodeTypeNonWordBoundary:
+		re = singleTransitionRegex(WordBoundaryTransitioner{Negate: true})
+	case parser.NodeTypeNoWordBefore:
+		re = singleTransitionRegex(NoWordTransitioner{})
+	case parser.NodeTypeNoWordAfter:
+		re = singleTransitionRegex(NoWordTransitioner{After: true})
 	case parser.NodeTypeBackreference:
-		re = singleTransitionRegex(BackreferenceTransitioner{node.GroupName})
+		re = singleTransitionRegex(BackreferenceTransitioner{GroupName: node.GroupName, FoldCase: c.foldCase(node)})
 	case parser.NodeTypeAlternation:
-		return compileAlternation(node, grpNum)
+		return c.compileAlternation(node)
 	case parser.NodeTypeGroup:
-		return compileGroup(node, grpNum)
+		return c.compileGroup(node)
+	case parser.NodeTypeLookahead, parser.NodeTypeNegativeLookahead,
+		parser.NodeTypeLookbehind, parser.NodeTypeNegativeLookbehind:
+		return c.compileLookaround(node)
 	default:
 		return nil, fmt.Errorf("unknown expression type: %T", node)
 	}
@@ -33,42 +119,58 @@ func compile(node *parser.RegexNode, grpNum *int) (*CompiledRegex, error) {
 	return re, nil
 }
 
+// foldCase reports whether a match or backreference node ignores case, either from an
+// inline flag or from the compile options.
+func (c *compiler) foldCase(node *parser.RegexNode) bool {
+	switch node.Case {
+	case parser.CaseInsensitive:
+		return true
+	case parser.CaseSensitive:
+		return false
+	}
+	return c.opts.CaseInsensitive
+}
+
+// matcher returns the matcher of a match node, folded to match both cases if needed.
+func (c *compiler) matcher(node *parser.RegexNode) parser.Matcher {
+	if c.foldCase(node) {
+		return parser.WithFoldCase(node.Value)
+	}
+	return node.Value
+}
+
 // singleTransitionRegex creates a regex with a single transition from start to end
 func singleTransitionRegex(tr Transitioner) *CompiledRegex {
 	start := NewState()
 	end := NewState()
 	start.AddTransition(end, tr)
 
-	return &CompiledRegex{start, end}
+	return &CompiledRegex{initialState: start, endingState: end}
 }
 
-// singleMatchRegex creates a regex that matches a single character
+// singleMatchRegex creates a regex that matches a single character, or a whole rune for
+// sets parsed in UTF-8 mode
 func singleMatchRegex(m parser.Matcher) *CompiledRegex {
+	if cg, ok := m.(*parser.CharGroupMatcher); ok && cg.UTF8 {
+		return singleTransitionRegex(RuneTransitioner{cg})
+	}
 	return singleTransitionRegex(CharTransitioner{m})
 }
 
 // compileAlternation compiles an alternation node into a CompiledRegex
 // i.e a|b
-func compileAlternation(node *parser.RegexNode, grpNum *int) (*CompiledRegex, error) {
-	var grpName string
-	if node.Capturing {
-		if node.GroupName != "" {
-			grpName = node.GroupName
-		} else {
-			grpName = fmt.Sprintf("%d", *grpNum)
-			*grpNum++
-		}
-	}
+func (c *compiler) compileAlternation(node *parser.RegexNode) (*CompiledRegex, error) {
+	grpNames := c.groupNames(node)
 
 	start, end := NewState(), NewState()
-	re := &CompiledRegex{start, end}
+	re := &CompiledRegex{initialState: start, endingState: end}
 
 	// Add an union for each alternative
 	for _, child := range node.Children {
 		var subRe *CompiledRegex
 		var err error
 
-		subRe, err = compile(child, grpNum)
+		subRe, err = c.compile(child)
 		if err != nil {
 			return nil, err
 		}
@@ -78,7 +180,7 @@ func compileAlternation(node *parser.RegexNode, grpNum *int) (*CompiledRegex, er
 		subRe.endingState.AddTransition(end, EpsilonTransitioner{})
 	}
 
-	if node.Capturing {
+	for _, grpName := range grpNames {
 		re.initialState.AddStartingGroup(grpName)
 		re.endingState.AddEndingGroup(grpName)
 	}
@@ -88,25 +190,17 @@ func compileAlternation(node *parser.RegexNode, grpNum *int) (*CompiledRegex, er
 	return re, nil
 }
 
-func compileGroup(node *parser.RegexNode, grpNum *int) (*CompiledRegex, error) {
-	var grpName string
+func (c *compiler) compileGroup(node *parser.RegexNode) (*CompiledRegex, error) {
 	var re *CompiledRegex
 
 	if len(node.Children) == 0 {
 		return singleTransitionRegex(EpsilonTransitioner{}), nil
 	}
 
-	if node.Capturing {
-		if node.GroupName != "" {
-			grpName = node.GroupName
-		} else {
-			grpName = fmt.Sprintf("%d", *grpNum)
-			*grpNum++
-		}
-	}
+	grpNames := c.groupNames(node)
 
 	for _, child := range node.Children {
-		current, err := compile(child, grpNum)
+		current, err := c.compile(child)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compile child in group: %w", err)
 		}
@@ -118,7 +212,7 @@ func compileGroup(node *parser.RegexNode, grpNum *int) (*CompiledRegex, error) {
 		}
 	}
 
-	if node.Capturing && re != nil {
+	for _, grpName := range grpNames {
 		re.initialState.AddStartingGroup(grpName)
 		re.endingState.AddEndingGroup(grpName)
 	}
@@ -128,37 +222,228 @@ func compileGroup(node *parser.RegexNode, grpNum *int) (*CompiledRegex, error) {
 	return re, nil
 }
 
+// compileLookaround compiles an assertion into a zero-width transition carrying the
+// assertion body as its own NFA. Groups inside the body share the pattern's numbering.
+func (c *compiler) compileLookaround(node *parser.RegexNode) (*CompiledRegex, error) {
+	body, err := c.compileGroup(parser.NewGroup(node.Children))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile lookaround: %w", err)
+	}
+
+	tr := LookaroundTransitioner{
+		Regex:  body,
+		Behind: node.Type == parser.NodeTypeLookbehind || node.Type == parser.NodeTypeNegativeLookbehind,
+		Negate: node.Type == parser.NodeTypeNegativeLookahead || node.Type == parser.NodeTypeNegativeLookbehind,
+		UTF8:   c.opts.UTF8,
+	}
+	if tr.Behind {
+		tr.MinWidth, tr.MaxWidth = nodeWidth(parser.NewGroup(node.Children))
+	}
+
+	re := singleTransitionRegex(tr)
+	processQuantifier(re, node.Quantifier)
+
+	return re, nil
+}
+
+// nodeWidth returns the minimum and maximum number of bytes a node can match.
+// The maximum is -1 when it is unbounded.
+func nodeWidth(node *parser.RegexNode) (int, int) {
+	lo, hi := 0, 0
+	switch node.Type {
+	case parser.NodeTypeMatch:
+		lo, hi = 1, 1
+		if cg, ok := node.Value.(*parser.CharGroupMatcher); ok && cg.UTF8 {
+			hi = utf8.UTFMax
+		}
+	case parser.NodeTypeBackreference:
+		lo, hi = 0, -1
+	case parser.NodeTypeGroup:
+		for _, child := range node.Children {
+			cLo, cHi := nodeWidth(child)
+			lo += cLo
+			hi = addWidth(hi, cHi)
+		}
+	case parser.NodeTypeAlternation:
+		for i, child := range node.Children {
+			cLo, cHi := nodeWidth(child)
+			if i == 0 || cLo < lo {
+				lo = cLo
+			}
+			if hi != -1 && (cHi == -1 || cHi > hi) {
+				hi = cHi
+			}
+		}
+	}
+
+	q := node.Quantifier
+	switch {
+	case q.Range():
+		lo *= node.Min
+		if hi != 0 {
+			if hi == -1 || node.Max == -1 {
+				hi = -1
+			} else {
+				hi *= node.Max
+			}
+		}
+	case q.Plus():
+		if hi != 0 {
+			hi = -1
+		}
+	case q.Asterisk():
+		lo = 0
+		if hi != 0 {
+			hi = -1
+		}
+	case q.Optional():
+		lo = 0
+	}
+
+	return lo, hi
+}
+
+func addWidth(a, b int) int {
+	if a == -1 || b == -1 {
+		return -1
+	}
+	return a + b
+}
+
+// groupNames allocates the names a capturing group is tracked under: its number and,
+// for a named group, also its name so it can be referenced either way.
+func (c *compiler) groupNames(node *parser.RegexNode) []string {
+	if !node.Capturing {
+		return nil
+	}
+
+	names := []string{fmt.Sprintf("%d", c.grpNum)}
+	if c.grpNum == len(c.names) { // Repeated copies of a group reuse its number
+		c.names = append(c.names, node.GroupName)
+	}
+	c.grpNum++
+	if node.GroupName != "" {
+		names = append(names, node.GroupName)
+	}
+
+	return names
+}
+
+// compileRange compiles a bounded repetition x{min,max} by expanding the node into copies.
+// Every copy is compiled with the same group numbers, so a repeated capturing group keeps
+// its name and reports the last iteration, like x+ does.
+func (c *compiler) compileRange(node *parser.RegexNode) (*CompiledRegex, error) {
+	single := *node
+	single.Quantifier = parser.QuantifierNone
+
+	copies := node.Max
+	if node.Max == -1 {
+		copies = node.Min + 1 // the last copy is starred
+	}
+
+	first := c.grpNum
+	if copies == 0 {
+		// x{0} matches the empty string, but its groups still take up numbers
+		if _, err := c.compile(&single); err != nil {
+			return nil, err
+		}
+		return singleTransitionRegex(EpsilonTransitioner{}), nil
+	}
+
+	fragments := make([]*CompiledRegex, 0, copies)
+	for range copies {
+		c.grpNum = first
+		re, err := c.compile(&single)
+		if err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, re)
+	}
+
+	return withRange(fragments, node.Min, node.Max, node.Quantifier.Lazy()), nil
+}
+
 // processQuantifier modifies the base regex according to the quantifier
 func processQuantifier(base *CompiledRegex, q parser.Quantifier) {
+	lazy := q.Lazy()
 	if q.Plus() {
-		withPlus(base)
+		withPlus(base, lazy)
 	} else if q.Asterisk() {
-		withAsterisk(base)
+		withAsterisk(base, lazy)
 	} else if q.Optional() {
-		withOptional(base)
+		withOptional(base, lazy)
 	}
 }
 
-// withPlus modifies the base regex to match one or more times
-func withPlus(base *CompiledRegex) {
+// withPlus modifies the base regex to match one or more times.
+// The matcher follows transitions in order, so a greedy loop tries the loop-back first
+// while a lazy loop tries to leave first.
+func withPlus(base *CompiledRegex, lazy bool) {
 	start := NewState()
 	end := NewState()
 
 	start.AddTransition(base.initialState, EpsilonTransitioner{})
-	base.endingState.AddTransition(base.initialState, EpsilonTransitioner{})
-	base.endingState.AddTransition(end, EpsilonTransitioner{})
+	if lazy {
+		base.endingState.AddTransition(end, EpsilonTransitioner{})
+		base.endingState.AddTransition(base.initialState, EpsilonTransitioner{})
+	} else {
+		base.endingState.AddTransition(base.initialState, EpsilonTransitioner{})
+		base.endingState.AddTransition(end, EpsilonTransitioner{})
+	}
 
 	base.initialState = start
 	base.endingState = end
 }
 
 // withOptional modifies the base regex to match zero or one time
-func withOptional(base *CompiledRegex) {
-	base.initialState.AddTransition(base.endingState, EpsilonTransitioner{})
+func withOptional(base *CompiledRegex, lazy bool) {
+	// Skip from outside a group so that a skipped group is not captured as empty
+	if len(base.initialState.StartingGroups) > 0 || len(base.endingState.EndingGroups) > 0 {
+		start, end := NewState(), NewState()
+		start.AddTransition(base.initialState, EpsilonTransitioner{})
+		base.endingState.AddTransition(end, EpsilonTransitioner{})
+		base.initialState, base.endingState = start, end
+	}
+
+	if lazy {
+		base.initialState.PrependTransition(base.endingState, EpsilonTransitioner{})
+	} else {
+		base.initialState.AddTransition(base.endingState, EpsilonTransitioner{})
+	}
 }
 
 // withAsterisk modifies the base regex to match zero or more times
-func withAsterisk(base *CompiledRegex) {
-	withPlus(base)
-	withOptional(base)
+func withAsterisk(base *CompiledRegex, lazy bool) {
+	withPlus(base, lazy)
+	withOptional(base, lazy)
+}
+
+// withRange chains copies of a regex so that the first min copies are mandatory and the rest
+// are optional; with max -1 the last copy repeats zero or more times. A lazy range tries to
+// skip the optional copies before entering them.
+// Copies are joined with epsilon transitions instead of merged states so a group ending in
+// one copy and starting again in the next are tracked on distinct states.
+func withRange(fragments []*CompiledRegex, min, max int, lazy bool) *CompiledRegex {
+	start, end := NewState(), NewState()
+
+	cur := start
+	for i, f := range fragments {
+		if max == -1 && i == len(fragments)-1 {
+			withAsterisk(f, lazy)
+		}
+
+		cur.AddTransition(f.initialState, EpsilonTransitioner{})
+		if max != -1 && i >= min {
+			// Optional copy: skip all remaining copies
+			if lazy {
+				cur.PrependTransition(end, EpsilonTransitioner{})
+			} else {
+				cur.AddTransition(end, EpsilonTransitioner{})
+			}
+		}
+		cur = f.endingState
+	}
+	cur.AddTransition(end, EpsilonTransitioner{})
+
+	return &CompiledRegex{initialState: start, endingState: end}
 }
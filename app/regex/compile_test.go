@@ -360,6 +360,179 @@ func TestCompile(t *testing.T) {
 				return &CompiledRegex{initialState: s0, endingState: s2}
 			},
 		},
+		{
+			name: "single char with lazy plus quantifier", // a+?
+			root: &parser.RegexNode{
+				Type: parser.NodeTypeGroup,
+				Children: []*parser.RegexNode{
+					parser.NewLiteralMatch('a').WithQuantifier(parser.QuantifierPlus | parser.QuantifierLazy),
+				},
+			},
+			want: func() *CompiledRegex {
+				s0 := NewState()
+				s1 := NewState()
+				s2 := NewState()
+				s3 := NewState()
+				s0.AddTransition(s1, EpsilonTransitioner{})
+				s1.AddTransition(s2, literalCharTransitioner('a'))
+				s2.AddTransition(s3, EpsilonTransitioner{}) // leave first
+				s2.AddTransition(s1, EpsilonTransitioner{})
+
+				return &CompiledRegex{initialState: s0, endingState: s3}
+			},
+		},
+		{
+			name: "named and non-capturing groups", // (?<x>a)(?:b)
+			root: &parser.RegexNode{
+				Type: parser.NodeTypeGroup,
+				Children: []*parser.RegexNode{
+					{
+						Type:      parser.NodeTypeGroup,
+						Children:  []*parser.RegexNode{parser.NewLiteralMatch('a')},
+						Capturing: true,
+						GroupName: "x",
+					},
+					{
+						Type:     parser.NodeTypeGroup,
+						Children: []*parser.RegexNode{parser.NewLiteralMatch('b')},
+					},
+				},
+				Capturing: true,
+			},
+			want: func() *CompiledRegex {
+				s0 := NewState()
+				s1 := NewState()
+				s2 := NewState()
+				s0.AddStartingGroup("0")
+				s0.AddStartingGroup("1")
+				s0.AddStartingGroup("x")
+				s0.AddTransition(s1, literalCharTransitioner('a'))
+				s1.AddEndingGroup("1")
+				s1.AddEndingGroup("x")
+				s1.AddTransition(s2, literalCharTransitioner('b'))
+				s2.AddEndingGroup("0")
+
+				return &CompiledRegex{initialState: s0, endingState: s2}
+			},
+		},
+		{
+			name: "negative lookbehind", // (?<!ab?)c
+			root: &parser.RegexNode{
+				Type: parser.NodeTypeGroup,
+				Children: []*parser.RegexNode{
+					parser.NewLookaround(parser.NodeTypeNegativeLookbehind, []*parser.RegexNode{
+						parser.NewLiteralMatch('a'),
+						parser.NewLiteralMatch('b').WithQuantifier(parser.QuantifierOptional),
+					}),
+					parser.NewLiteralMatch('c'),
+				},
+			},
+			want: func() *CompiledRegex {
+				b0 := NewState()
+				b1 := NewState()
+				b2 := NewState()
+				b0.AddTransition(b1, literalCharTransitioner('a'))
+				b1.AddTransition(b2, literalCharTransitioner('b'))
+				b1.AddTransition(b2, EpsilonTransitioner{})
+				body := &CompiledRegex{initialState: b0, endingState: b2}
+
+				s0 := NewState()
+				s1 := NewState()
+				s2 := NewState()
+				s0.AddTransition(s1, LookaroundTransitioner{Regex: body, Behind: true, Negate: true, MinWidth: 1, MaxWidth: 2})
+				s1.AddTransition(s2, literalCharTransitioner('c'))
+
+				return &CompiledRegex{initialState: s0, endingState: s2}
+			},
+		},
+		{
+			name: "bounded repetition", // a{1,2}
+			root: &parser.RegexNode{
+				Type: parser.NodeTypeGroup,
+				Children: []*parser.RegexNode{
+					parser.NewLiteralMatch('a').WithRange(1, 2),
+				},
+			},
+			want: func() *CompiledRegex {
+				s := make([]*State, 6)
+				for i := range s {
+					s[i] = NewState()
+				}
+				s[0].AddTransition(s[1], EpsilonTransitioner{})
+				s[1].AddTransition(s[2], literalCharTransitioner('a'))
+				s[2].AddTransition(s[3], EpsilonTransitioner{})
+				s[2].AddTransition(s[5], EpsilonTransitioner{}) // skip the optional copy
+				s[3].AddTransition(s[4], literalCharTransitioner('a'))
+				s[4].AddTransition(s[5], EpsilonTransitioner{})
+
+				return &CompiledRegex{initialState: s[0], endingState: s[5]}
+			},
+		},
+		{
+			name: "repeated capturing group keeps its number", // (a){2}b
+			root: &parser.RegexNode{
+				Type: parser.NodeTypeGroup,
+				Children: []*parser.RegexNode{
+					(&parser.RegexNode{
+						Type:      parser.NodeTypeGroup,
+						Children:  []*parser.RegexNode{parser.NewLiteralMatch('a')},
+						Capturing: true,
+					}).WithRange(2, 2),
+					parser.NewLiteralMatch('b'),
+				},
+				Capturing: true,
+			},
+			want: func() *CompiledRegex {
+				s := make([]*State, 7)
+				for i := range s {
+					s[i] = NewState()
+				}
+				s[0].AddStartingGroup("0")
+				s[0].AddTransition(s[1], EpsilonTransitioner{})
+				s[1].AddStartingGroup("1")
+				s[1].AddTransition(s[2], literalCharTransitioner('a'))
+				s[2].AddEndingGroup("1")
+				s[2].AddTransition(s[3], EpsilonTransitioner{})
+				s[3].AddStartingGroup("1")
+				s[3].AddTransition(s[4], literalCharTransitioner('a'))
+				s[4].AddEndingGroup("1")
+				s[4].AddTransition(s[5], EpsilonTransitioner{})
+				s[5].AddTransition(s[6], literalCharTransitioner('b'))
+				s[6].AddEndingGroup("0")
+
+				return &CompiledRegex{initialState: s[0], endingState: s[6]}
+			},
+		},
+		{
+			name: "optional capturing group is skipped from outside the group", // (a)?b
+			root: &parser.RegexNode{
+				Type: parser.NodeTypeGroup,
+				Children: []*parser.RegexNode{
+					{
+						Type:       parser.NodeTypeGroup,
+						Children:   []*parser.RegexNode{parser.NewLiteralMatch('a')},
+						Capturing:  true,
+						Quantifier: parser.QuantifierOptional,
+					},
+					parser.NewLiteralMatch('b'),
+				},
+			},
+			want: func() *CompiledRegex {
+				s := make([]*State, 5)
+				for i := range s {
+					s[i] = NewState()
+				}
+				s[0].AddTransition(s[1], EpsilonTransitioner{})
+				s[0].AddTransition(s[3], EpsilonTransitioner{})
+				s[1].AddStartingGroup("0")
+				s[1].AddTransition(s[2], literalCharTransitioner('a'))
+				s[2].AddEndingGroup("0")
+				s[2].AddTransition(s[3], EpsilonTransitioner{})
+				s[3].AddTransition(s[4], literalCharTransitioner('b'))
+
+				return &CompiledRegex{initialState: s[0], endingState: s[4]}
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,216 @@
+package regex
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/grep-starter-go/app/parser"
+)
+
+// Literals are byte strings found in every match of a regex. Engines use them to reject
+// input that cannot match and to skip ahead to where a match can start.
+type Literals struct {
+	Prefix   []byte // Every match starts with Prefix
+	Required []byte // Every match contains Required, the longest such literal found
+}
+
+// literalInfo describes the literals known to appear in every match of a node.
+type literalInfo struct {
+	exact  bool   // The node always matches exactly lit
+	lit    []byte // Set when exact
+	prefix []byte // Every match starts with prefix
+	suffix []byte // Every match ends with suffix
+	inner  []byte // Every match contains inner
+}
+
+func exactLiteral(lit []byte) literalInfo {
+	return literalInfo{exact: true, lit: lit, prefix: lit, suffix: lit, inner: lit}
+}
+
+// literals extracts the required literals of a pattern. Parts matched with case folding
+// are treated as unknown.
+func (c *compiler) literals(node *parser.RegexNode) Literals {
+	info := c.literalInfo(node)
+	return Literals{Prefix: info.prefix, Required: info.inner}
+}
+
+func (c *compiler) literalInfo(node *parser.RegexNode) literalInfo {
+	var info literalInfo
+	switch node.Type {
+	case parser.NodeTypeMatch:
+		if lit, ok := matcherLiteral(c.matcher(node)); ok {
+			info = exactLiteral(lit)
+		}
+	case parser.NodeTypeCaretAnchor, parser.NodeTypeDollorAnchor,
+		parser.NodeTypeWordBoundary, parser.NodeTypeNonWordBoundary,
+		parser.NodeTypeNoWordBefore, parser.NodeTypeNoWordAfter,
+		parser.NodeTypeLookahead, parser.NodeTypeNegativeLookahead,
+		parser.NodeTypeLookbehind, parser.NodeTypeNegativeLookbehind:
+		// Zero-width, so they neither add to nor break up the literals around them
+		info = exactLiteral(nil)
+	case parser.NodeTypeGroup:
+		info = exactLiteral(nil)
+		for _, child := range node.Children {
+			info = concatLiterals(info, c.literalInfo(child))
+		}
+	case parser.NodeTypeAlternation:
+		for i, child := range node.Children {
+			if i == 0 {
+				info = c.literalInfo(child)
+			} else {
+				info = altLiterals(info, c.literalInfo(child))
+			}
+		}
+	}
+
+	q := node.Quantifier
+	switch {
+	case q.Asterisk() || q.Optional() || (q.Range() && node.Min == 0):
+		return literalInfo{}
+	case q.Plus() || (q.Range() && (node.Min != 1 || node.Max != 1)):
+		// At least one repetition, but the literals are no longer all of the match
+		info.exact, info.lit = false, nil
+	}
+
+	return info
+}
+
+// concatLiterals combines the literals of two nodes matched one after the other.
+func concatLiterals(a, b literalInfo) literalInfo {
+	info := literalInfo{
+		prefix: a.prefix,
+		suffix: b.suffix,
+	}
+	if a.exact {
+		info.prefix = concat(a.lit, b.prefix)
+	}
+	if b.exact {
+		info.suffix = concat(a.suffix, b.lit)
+	}
+	if a.exact && b.exact {
+		info.exact = true
+		info.lit = concat(a.lit, b.lit)
+	}
+	info.inner = longest(a.inner, b.inner, concat(a.suffix, b.prefix), info.prefix, info.suffix)
+
+	return info
+}
+
+// altLiterals combines the literals of two alternatives.
+func altLiterals(a, b literalInfo) literalInfo {
+	info := literalInfo{
+		exact:  a.exact && b.exact && bytes.Equal(a.lit, b.lit),
+		lit:    a.lit,
+		prefix: commonPrefix(a.prefix, b.prefix),
+		suffix: commonSuffix(a.suffix, b.suffix),
+	}
+	info.inner = longest(info.prefix, info.suffix)
+	if info.exact {
+		info.inner = info.lit
+	}
+
+	return info
+}
+
+// matcherLiteral returns the bytes matched by m if it matches a single fixed character.
+func matcherLiteral(m parser.Matcher) ([]byte, bool) {
+	switch m := m.(type) {
+	case *parser.LiteralMatcher:
+		if !m.FoldCase {
+			return []byte{m.Char}, true
+		}
+	case *parser.CharGroupMatcher:
+		if m.Negate || m.FoldCase || len(m.Tables) > 0 || len(m.Classes) > 0 {
+			return nil, false
+		}
+		if len(m.Chars) == 1 && len(m.Ranges) == 0 && len(m.RuneRanges) == 0 && !m.UTF8 {
+			return []byte{m.Chars[0]}, true
+		}
+		if len(m.Chars) == 0 && len(m.Ranges) == 0 && len(m.RuneRanges) == 1 && m.UTF8 &&
+			m.RuneRanges[0][0] == m.RuneRanges[0][1] {
+			return utf8.AppendRune(nil, m.RuneRanges[0][0]), true
+		}
+	}
+
+	return nil, false
+}
+
+func concat(a, b []byte) []byte {
+	return append(append([]byte(nil), a...), b...)
+}
+
+func commonPrefix(a, b []byte) []byte {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+func commonSuffix(a, b []byte) []byte {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return a[len(a)-n:]
+}
+
+func longest(candidates ...[]byte) []byte {
+	var best []byte
+	for _, c := range candidates {
+		if len(c) > len(best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// Finder searches for a literal using the Boyer-Moore-Horspool algorithm, which skips
+// ahead by up to the length of the literal after each mismatch. Short literals are left
+// to bytes.Index, which is faster for them.
+type Finder struct {
+	pattern []byte
+	skip    [256]int
+}
+
+// horspoolMinLen is the shortest literal searched with the Horspool skip table.
+const horspoolMinLen = 8
+
+// NewFinder prepares a search for pattern. It returns nil for an empty pattern.
+func NewFinder(pattern []byte) *Finder {
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	f := &Finder{pattern: pattern}
+	for i := range f.skip {
+		f.skip[i] = len(pattern)
+	}
+	for i, c := range pattern[:len(pattern)-1] {
+		f.skip[c] = len(pattern) - 1 - i
+	}
+
+	return f
+}
+
+// Index returns the index of the first occurrence of the pattern in text, or -1.
+func (f *Finder) Index(text []byte) int {
+	n := len(f.pattern)
+	if n < horspoolMinLen {
+		return bytes.Index(text, f.pattern)
+	}
+
+	last := f.pattern[n-1]
+	for i := 0; i+n <= len(text); i += f.skip[text[i+n-1]] {
+		if text[i+n-1] == last && bytes.Equal(text[i:i+n-1], f.pattern[:n-1]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Pattern returns the literal searched for.
+func (f *Finder) Pattern() []byte {
+	return f.pattern
+}
@@ -0,0 +1,99 @@
+package regex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/grep-starter-go/app/parser"
+)
+
+func TestCompile_Literals(t *testing.T) {
+	tests := []struct {
+		pattern      string
+		opts         Options
+		wantPrefix   string
+		wantRequired string
+	}{
+		{pattern: `abc`, wantPrefix: "abc", wantRequired: "abc"},
+		{pattern: `^ERROR.*timeout$`, wantPrefix: "ERROR", wantRequired: "timeout"},
+		{pattern: `\d+ apples`, wantPrefix: "", wantRequired: " apples"},
+		{pattern: `(foo|foobar)baz`, wantPrefix: "foo", wantRequired: "foo"},
+		{pattern: `(get|set)Value`, wantPrefix: "", wantRequired: "etValue"},
+		{pattern: `x(ab)+y`, wantPrefix: "xab", wantRequired: "xab"},
+		{pattern: `x(ab)*y`, wantPrefix: "x", wantRequired: "x"},
+		{pattern: `a{2}b`, wantPrefix: "a", wantRequired: "ab"},
+		{pattern: `\bcat\b`, wantPrefix: "cat", wantRequired: "cat"},
+		{pattern: `(?<=\$)42`, wantPrefix: "42", wantRequired: "42"},
+		{pattern: `\.txt`, wantPrefix: ".txt", wantRequired: ".txt"},
+		{pattern: `a|b`, wantPrefix: "", wantRequired: ""},
+		{pattern: `(\w+) \1 end`, wantPrefix: "", wantRequired: " end"},
+		{pattern: `abc`, opts: Options{CaseInsensitive: true}, wantPrefix: "", wantRequired: ""},
+		{pattern: `(?i)abc(?-i)DEF`, wantPrefix: "", wantRequired: "DEF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			node, err := parser.New(tt.pattern).Parse()
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			re, err := CompileWithOptions(node, tt.opts)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+
+			got := re.Literals()
+			if string(got.Prefix) != tt.wantPrefix {
+				t.Errorf("Prefix = %q, want %q", got.Prefix, tt.wantPrefix)
+			}
+			if string(got.Required) != tt.wantRequired {
+				t.Errorf("Required = %q, want %q", got.Required, tt.wantRequired)
+			}
+		})
+	}
+}
+
+func TestFinder_Index(t *testing.T) {
+	text := strings.Repeat("lorem ipsum dolor ", 20) + "connection timeout after 30s"
+	tests := []struct {
+		pattern string
+		text    string
+		want    int
+	}{
+		{pattern: "timeout", text: text, want: strings.Index(text, "timeout")},
+		{pattern: "connection timeout", text: text, want: strings.Index(text, "connection timeout")},
+		{pattern: "connection refused", text: text, want: -1},
+		{pattern: "after 30s", text: text, want: len(text) - len("after 30s")},
+		{pattern: "aaaaaaab", text: "aaaaaaaaaaaaaab", want: 7},
+		{pattern: "longer than text", text: "short", want: -1},
+	}
+
+	for _, tt := range tests {
+		if got := NewFinder([]byte(tt.pattern)).Index([]byte(tt.text)); got != tt.want {
+			t.Errorf("Index(%q) = %d, want %d", tt.pattern, got, tt.want)
+		}
+	}
+
+	if NewFinder(nil) != nil {
+		t.Errorf("NewFinder(nil) should be nil")
+	}
+}
+
+func TestCompileMulti_Literals(t *testing.T) {
+	var nodes []*parser.RegexNode
+	for _, pattern := range []string{`error: disk`, `error: (net|dns)`} {
+		node, err := parser.New(pattern).Parse()
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	re, err := CompileMulti(nodes, Options{})
+	if err != nil {
+		t.Fatalf("CompileMulti() error = %v", err)
+	}
+	if got := re.Literals(); string(got.Prefix) != "error: " || string(got.Required) != "error: " {
+		t.Errorf("Literals() = {%q, %q}, want both %q", got.Prefix, got.Required, "error: ")
+	}
+}
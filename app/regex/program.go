@@ -0,0 +1,118 @@
+package regex
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Program is a flattened, read-only view of a CompiledRegex used by the matching engines.
+// It is built once on first use, so a CompiledRegex can be shared between goroutines.
+type Program struct {
+	States []*State       // Reachable states, the initial state first
+	Index  map[*State]int // Position of each state in States
+	Groups []string       // Capture group names, numbered groups first in numeric order
+	// GroupIndex maps a group name to its position in Groups.
+	GroupIndex map[string]int
+	// NeedsBacktracking is set when the regex uses backreferences or captures groups
+	// inside a lookaround, which only the backtracking engine tracks.
+	NeedsBacktracking bool
+	// UTF8 is set when the regex was compiled in UTF-8 mode or consumes whole UTF-8
+	// encoded runes, so searches should not start inside one.
+	UTF8 bool
+	// Prefix and Required find the literals of the regex, or are nil if it has none.
+	Prefix   *Finder
+	Required *Finder
+}
+
+// Program returns the flattened view of re.
+func (re *CompiledRegex) Program() *Program {
+	re.programOnce.Do(func() {
+		re.program = newProgram(re)
+	})
+
+	return re.program
+}
+
+func newProgram(re *CompiledRegex) *Program {
+	prog := &Program{
+		Index:      map[*State]int{},
+		GroupIndex: map[string]int{},
+		Prefix:     NewFinder(re.literals.Prefix),
+		Required:   NewFinder(re.literals.Required),
+		UTF8:       re.utf8,
+	}
+
+	// Iterative DFS in transition order so state numbering matches BuildIDMap
+	stack := []*State{re.initialState}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, seen := prog.Index[s]; seen {
+			continue
+		}
+		prog.Index[s] = len(prog.States)
+		prog.States = append(prog.States, s)
+
+		for _, tr := range slices.Backward(s.Transitions) {
+			stack = append(stack, tr.To)
+		}
+	}
+
+	// Keep the ending state numbered even if nothing reaches it, such as when no patterns
+	// were combined
+	if _, seen := prog.Index[re.endingState]; !seen {
+		prog.Index[re.endingState] = len(prog.States)
+		prog.States = append(prog.States, re.endingState)
+	}
+
+	groups := map[string]bool{}
+	var visit func(states []*State, inLookaround bool)
+	visit = func(states []*State, inLookaround bool) {
+		for _, s := range states {
+			for _, name := range s.StartingGroups {
+				groups[name] = true
+				if inLookaround {
+					prog.NeedsBacktracking = true
+				}
+			}
+			for _, tr := range s.Transitions {
+				switch t := tr.Transitioner.(type) {
+				case BackreferenceTransitioner:
+					prog.NeedsBacktracking = true
+				case RuneTransitioner:
+					prog.UTF8 = true
+				case LookaroundTransitioner:
+					visit(t.Regex.Program().States, true)
+				}
+			}
+		}
+	}
+	visit(prog.States, false)
+
+	for name := range groups {
+		prog.Groups = append(prog.Groups, name)
+	}
+	slices.SortFunc(prog.Groups, compareGroupNames)
+	for i, name := range prog.Groups {
+		prog.GroupIndex[name] = i
+	}
+
+	return prog
+}
+
+// compareGroupNames orders numbered groups numerically before named groups.
+func compareGroupNames(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return na - nb
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
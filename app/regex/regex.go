@@ -4,12 +4,24 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/grep-starter-go/app/parser"
 )
 
-// CompiledRegex represents a compiled regular expression as an NFA.
+// CompiledRegex represents a compiled regular expression as an NFA. Once compiled it is
+// only read, so it is safe for concurrent use by the matchers.
 type CompiledRegex struct {
 	initialState *State
 	endingState  *State
+
+	literals    Literals // Set for regexes compiled from a pattern
+	subexpNames []string // Name of each numbered group, "" if unnamed
+	utf8        bool     // Compiled from a pattern parsed in UTF-8 mode
+
+	programOnce sync.Once
+	program     *Program
 }
 
 func (re *CompiledRegex) SetInitialState(s *State) {
@@ -28,6 +40,17 @@ func (re *CompiledRegex) EndingState() *State {
 	return re.endingState
 }
 
+// SubexpNames returns the names of the numbered capture groups, "" for unnamed ones. Group
+// 0 is the whole match. It is empty for regexes not compiled from a pattern.
+func (re *CompiledRegex) SubexpNames() []string {
+	return re.subexpNames
+}
+
+// Literals returns the literals every match contains.
+func (re *CompiledRegex) Literals() Literals {
+	return re.literals
+}
+
 // appendRegex connect the ending state of the current regex to the initial state of the other regex
 func (re *CompiledRegex) appendRegex(other *CompiledRegex) {
 	re.endingState.Append(other.initialState)
@@ -38,6 +61,7 @@ type State struct {
 	Transitions    []Transition
 	StartingGroups []string
 	EndingGroups   []string
+	Accepts        []int // Patterns of a combined regex whose match is complete here
 }
 
 func NewState() *State {
@@ -70,6 +94,7 @@ func (s *State) Append(other *State) {
 	s.Transitions = append(s.Transitions, other.Transitions...)
 	s.StartingGroups = append(s.StartingGroups, other.StartingGroups...)
 	s.EndingGroups = append(s.EndingGroups, other.EndingGroups...)
+	s.Accepts = append(s.Accepts, other.Accepts...)
 }
 
 type Stringer interface {
@@ -85,6 +110,9 @@ type MatchArg interface {
 	Pos() int
 	Input() []byte
 	Backreference(name string) (GroupSpan, bool)
+	// Lookaround reports whether re matches the input anchored at start. If end is not -1
+	// the match must finish exactly at end.
+	Lookaround(re *CompiledRegex, start, end int) bool
 }
 
 type GroupSpan interface {
@@ -124,6 +152,35 @@ func (m CharTransitioner) String() string {
 	return m.Matcher.String()
 }
 
+// RuneMatcher defines the interface for matching a decoded rune.
+type RuneMatcher interface {
+	MatchRune(r rune) bool
+	Stringer
+}
+
+// RuneTransitioner is a transition that consumes one UTF-8 encoded rune.
+// An invalid or truncated sequence is consumed one byte at a time as utf8.RuneError,
+// so invalid input only matches sets that accept U+FFFD, such as '.' or [^a].
+type RuneTransitioner struct {
+	RuneMatcher
+}
+
+func (m RuneTransitioner) Match(arg MatchArg) (int, bool) {
+	input, pos := arg.Input(), arg.Pos()
+
+	if pos >= len(input) {
+		return 0, false
+	}
+
+	r, size := utf8.DecodeRune(input[pos:])
+
+	return size, m.RuneMatcher.MatchRune(r)
+}
+
+func (m RuneTransitioner) String() string {
+	return m.RuneMatcher.String()
+}
+
 // EpsilonTransitioner is a transition that represents an epsilon transition.
 // It always matches without consuming any input.
 type EpsilonTransitioner struct{}
@@ -156,8 +213,53 @@ func (m StartOfStringTransitioner) String() string {
 	return "^"
 }
 
+// WordBoundaryTransitioner matches where a word character (as defined by parser.WordMatcher)
+// is next to a non-word character or the edge of the input. With Negate it matches \B.
+type WordBoundaryTransitioner struct {
+	Negate bool
+}
+
+func (m WordBoundaryTransitioner) Match(arg MatchArg) (int, bool) {
+	input, pos := arg.Input(), arg.Pos()
+
+	before := pos > 0 && pos <= len(input) && parser.WordMatcher.Match(input[pos-1])
+	after := pos < len(input) && parser.WordMatcher.Match(input[pos])
+
+	return 0, (before != after) != m.Negate
+}
+
+func (m WordBoundaryTransitioner) String() string {
+	if m.Negate {
+		return `\B`
+	}
+	return `\b`
+}
+
+// NoWordTransitioner matches where the character before, or with After the one after,
+// is not a word character or is past the edge of the input.
+type NoWordTransitioner struct {
+	After bool
+}
+
+func (m NoWordTransitioner) Match(arg MatchArg) (int, bool) {
+	input, pos := arg.Input(), arg.Pos()
+
+	if m.After {
+		return 0, pos >= len(input) || !parser.WordMatcher.Match(input[pos])
+	}
+	return 0, pos == 0 || pos > len(input) || !parser.WordMatcher.Match(input[pos-1])
+}
+
+func (m NoWordTransitioner) String() string {
+	if m.After {
+		return `(?!\w)`
+	}
+	return `(?<!\w)`
+}
+
 type BackreferenceTransitioner struct {
 	GroupName string
+	FoldCase  bool
 }
 
 func (m BackreferenceTransitioner) Match(arg MatchArg) (int, bool) {
@@ -176,7 +278,8 @@ func (m BackreferenceTransitioner) Match(arg MatchArg) (int, bool) {
 	}
 
 	for i := range length {
-		if input[match.Start()+i] != input[pos+i] {
+		a, b := input[match.Start()+i], input[pos+i]
+		if a != b && !(m.FoldCase && parser.EqualFold(a, b)) {
 			return 0, false
 		}
 	}
@@ -188,6 +291,61 @@ func (m BackreferenceTransitioner) String() string {
 	return fmt.Sprintf(`\%s`, m.GroupName)
 }
 
+// LookaroundTransitioner is a zero-width transition that asserts that a sub-regex matches
+// (or, when Negate is set, does not match) ahead of or behind the current position.
+type LookaroundTransitioner struct {
+	Regex  *CompiledRegex
+	Behind bool
+	Negate bool
+	// MinWidth and MaxWidth bound the length of a lookbehind match so only the
+	// possible starting positions are tried. MaxWidth is -1 when unbounded.
+	MinWidth int
+	MaxWidth int
+	// UTF8 keeps lookbehind starts off the continuation bytes of UTF-8 encoded runes.
+	UTF8 bool
+}
+
+func (m LookaroundTransitioner) Match(arg MatchArg) (int, bool) {
+	pos := arg.Pos()
+
+	found := false
+	if !m.Behind {
+		found = arg.Lookaround(m.Regex, pos, -1)
+	} else {
+		lowest := 0
+		if m.MaxWidth != -1 {
+			lowest = max(0, pos-m.MaxWidth)
+		}
+		// Try the farthest start first so a greedy body captures as much as it can
+		input := arg.Input()
+		utf8Mode := m.UTF8 || m.Regex.Program().UTF8
+		for start := lowest; start <= pos-m.MinWidth; start++ {
+			if utf8Mode && start < len(input) && !utf8.RuneStart(input[start]) {
+				continue
+			}
+			if arg.Lookaround(m.Regex, start, pos) {
+				found = true
+				break
+			}
+		}
+	}
+
+	return 0, found != m.Negate
+}
+
+func (m LookaroundTransitioner) String() string {
+	switch {
+	case m.Behind && m.Negate:
+		return "(?<!)"
+	case m.Behind:
+		return "(?<=)"
+	case m.Negate:
+		return "(?!)"
+	default:
+		return "(?=)"
+	}
+}
+
 func printRegex(w io.Writer, re *CompiledRegex) {
 	idMap := make(map[*State]int)
 	start := re.initialState
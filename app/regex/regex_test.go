@@ -208,6 +208,10 @@ func transitionersEqual(m1, m2 Transitioner) bool {
 		if v2, ok := m2.(CharTransitioner); ok {
 			return v1.Matcher.String() == v2.Matcher.String()
 		}
+	case RuneTransitioner:
+		if v2, ok := m2.(RuneTransitioner); ok {
+			return v1.RuneMatcher.String() == v2.RuneMatcher.String()
+		}
 	case EpsilonTransitioner:
 		_, ok := m2.(EpsilonTransitioner)
 		return ok
@@ -220,6 +224,14 @@ func transitionersEqual(m1, m2 Transitioner) bool {
 	case BackreferenceTransitioner:
 		v2, ok := m2.(BackreferenceTransitioner)
 		return ok && v1.GroupName == v2.GroupName
+	case WordBoundaryTransitioner:
+		v2, ok := m2.(WordBoundaryTransitioner)
+		return ok && v1.Negate == v2.Negate
+	case LookaroundTransitioner:
+		v2, ok := m2.(LookaroundTransitioner)
+		return ok && v1.Behind == v2.Behind && v1.Negate == v2.Negate &&
+			v1.MinWidth == v2.MinWidth && v1.MaxWidth == v2.MaxWidth &&
+			regexsEqual(v1.Regex, v2.Regex)
 	}
 
 	return false
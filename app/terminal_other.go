@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// isTerminal reports false where terminals cannot be told apart, so --color=auto prints
+// plain text.
+func isTerminal(*os.File) bool {
+	return false
+}